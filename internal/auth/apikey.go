@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"project_sem/internal/services"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+type APIKeyAuthenticator struct {
+	keys *services.APIKeyService
+}
+
+func NewAPIKeyAuthenticator(keys *services.APIKeyService) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+// Authenticate accepts the key in X-API-Key or as a bearer token.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = bearerToken(r)
+	}
+	if key == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	found, err := a.keys.Verify(r.Context(), key)
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{Subject: "apikey:" + found.Name, Scopes: found.Scopes, Tenant: found.Tenant}, nil
+}
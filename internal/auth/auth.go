@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"project_sem/internal/models"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Principal struct {
+	Subject string
+	Scopes  []string
+	// Tenant is set when the credentials are bound to one tenant.
+	Tenant string
+}
+
+// HasScope treats the admin scope as granting every other scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, models.ScopeAdmin)
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Anonymous grants every request all scopes. It is used when authentication
+// is disabled so that route guards behave the same in every mode.
+type Anonymous struct{}
+
+const AnonymousSubject = "anonymous"
+
+func (Anonymous) Authenticate(*http.Request) (*Principal, error) {
+	return &Principal{Subject: AnonymousSubject, Scopes: []string{models.ScopeAdmin}}, nil
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(contextKey{}).(*Principal)
+	return principal
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
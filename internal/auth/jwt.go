@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"project_sem/internal/config"
+	"project_sem/internal/models"
+)
+
+const (
+	RoleViewer   = "viewer"
+	RoleUploader = "uploader"
+	RoleAdmin    = "admin"
+)
+
+// roleScopes maps roles onto the scopes that route guards check, so API keys
+// and tokens are enforced the same way.
+var roleScopes = map[string][]string{
+	RoleViewer:   {models.ScopePricesRead},
+	RoleUploader: {models.ScopePricesRead, models.ScopePricesWrite},
+	RoleAdmin:    {models.ScopeAdmin},
+}
+
+const tokenLeeway = 30 * time.Second
+
+type JWTAuthenticator struct {
+	keys        *KeySet
+	parser      *jwt.Parser
+	rolesClaim  []string
+	tenantClaim string
+	roleValues  map[string][]string
+}
+
+func NewJWTAuthenticator(keys *KeySet, cfg config.AuthConfig) *JWTAuthenticator {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWTAuthenticator{
+		keys:        keys,
+		parser:      jwt.NewParser(options...),
+		rolesClaim:  strings.Split(cfg.RolesClaim, "."),
+		tenantClaim: cfg.TenantClaim,
+		roleValues: map[string][]string{
+			RoleViewer:   cfg.ViewerRoles,
+			RoleUploader: cfg.UploaderRoles,
+			RoleAdmin:    cfg.AdminRoles,
+		},
+	}
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	raw := bearerToken(r)
+	if raw == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return a.keys.Key(r.Context(), kid)
+	})
+	if errors.Is(err, ErrJWKSUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	var scopes []string
+	for _, role := range a.roles(claims) {
+		scopes = append(scopes, roleScopes[role]...)
+	}
+
+	principal := &Principal{Subject: subject, Scopes: scopes}
+	if a.tenantClaim != "" {
+		principal.Tenant, _ = claims[a.tenantClaim].(string)
+		// Only admins may act across tenants; any other token without the
+		// claim is most likely issued for the wrong audience.
+		if principal.Tenant == "" && !principal.HasScope(models.ScopeAdmin) {
+			return nil, fmt.Errorf("%w: token has no %s claim", ErrInvalidCredentials, a.tenantClaim)
+		}
+	}
+	return principal, nil
+}
+
+// roles returns the service roles granted by the configured claim, which
+// may be a list or a space-separated string.
+func (a *JWTAuthenticator) roles(claims jwt.MapClaims) []string {
+	var value any = map[string]any(claims)
+	for _, key := range a.rolesClaim {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+
+	var granted []string
+	switch v := value.(type) {
+	case string:
+		granted = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				granted = append(granted, s)
+			}
+		}
+	}
+
+	var roles []string
+	for role, values := range a.roleValues {
+		for _, g := range granted {
+			if slices.Contains(values, g) {
+				roles = append(roles, role)
+				break
+			}
+		}
+	}
+	return roles
+}
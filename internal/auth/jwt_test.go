@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"project_sem/internal/config"
+	"project_sem/internal/models"
+)
+
+// testIssuer stands in for the identity provider: it signs tokens and
+// serves the JWKS of its current keys.
+type testIssuer struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	requests int
+}
+
+func newTestIssuer(t *testing.T) (*testIssuer, *httptest.Server) {
+	t.Helper()
+
+	issuer := &testIssuer{t: t, keys: make(map[string]*rsa.PrivateKey)}
+	issuer.addKey()
+	server := httptest.NewServer(issuer)
+	t.Cleanup(server.Close)
+	return issuer, server
+}
+
+func (i *testIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.requests++
+	var set JWKS
+	for _, key := range i.keys {
+		jwk, err := RSAPublicJWK(&key.PublicKey)
+		if err != nil {
+			i.t.Errorf("RSAPublicJWK: %v", err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+// addKey adds a signing key to the JWKS and returns its kid.
+func (i *testIssuer) addKey() string {
+	key := testKey(i.t)
+	jwk, err := RSAPublicJWK(&key.PublicKey)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys[jwk.Kid] = key
+	return jwk.Kid
+}
+
+func (i *testIssuer) kid() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for kid := range i.keys {
+		return kid
+	}
+	return ""
+}
+
+func (i *testIssuer) fetches() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.requests
+}
+
+func (i *testIssuer) sign(kid string, claims jwt.MapClaims) string {
+	i.mu.Lock()
+	key := i.keys[kid]
+	i.mu.Unlock()
+	return signToken(i.t, key, kid, claims)
+}
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func testAuthConfig(jwks string) config.AuthConfig {
+	return config.AuthConfig{
+		Mode:          "jwt",
+		JWKS:          jwks,
+		Issuer:        "https://sso.example.com",
+		Audience:      "prices",
+		RolesClaim:    "realm_access.roles",
+		TenantClaim:   "tenant",
+		ViewerRoles:   []string{"price-viewer"},
+		UploaderRoles: []string{"price-uploader"},
+		AdminRoles:    []string{"price-admin"},
+	}
+}
+
+// claims returns valid claims granting roles, which the caller may change.
+func claims(roles ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":          "alice",
+		"iss":          "https://sso.example.com",
+		"aud":          "prices",
+		"exp":          time.Now().Add(time.Hour).Unix(),
+		"tenant":       "acme",
+		"realm_access": map[string]any{"roles": roles},
+	}
+}
+
+func newTestAuthenticator(t *testing.T) (*JWTAuthenticator, *testIssuer, *KeySet) {
+	t.Helper()
+
+	issuer, server := newTestIssuer(t)
+	cfg := testAuthConfig(server.URL)
+	keys, err := NewKeySet(context.Background(), cfg.JWKS, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return NewJWTAuthenticator(keys, cfg), issuer, keys
+}
+
+func authenticate(a *JWTAuthenticator, token string) (*Principal, error) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v0/prices", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return a.Authenticate(r)
+}
+
+func TestJWTRoles(t *testing.T) {
+	a, issuer, _ := newTestAuthenticator(t)
+	kid := issuer.kid()
+
+	for _, tc := range []struct {
+		roles  []string
+		scopes []string
+	}{
+		{[]string{"price-viewer"}, []string{models.ScopePricesRead}},
+		{[]string{"price-uploader", "unrelated"}, []string{models.ScopePricesRead, models.ScopePricesWrite}},
+		{[]string{"price-admin"}, []string{models.ScopeAdmin}},
+		{[]string{"unrelated"}, nil},
+	} {
+		principal, err := authenticate(a, issuer.sign(kid, claims(tc.roles...)))
+		if err != nil {
+			t.Fatalf("%v: Authenticate: %v", tc.roles, err)
+		}
+		if principal.Subject != "alice" || principal.Tenant != "acme" {
+			t.Errorf("%v: principal %+v", tc.roles, principal)
+		}
+		got := slices.Clone(principal.Scopes)
+		slices.Sort(got)
+		if !slices.Equal(got, tc.scopes) {
+			t.Errorf("%v: scopes %v, want %v", tc.roles, got, tc.scopes)
+		}
+	}
+
+	// The claim may also be a space-separated string.
+	c := claims()
+	c["realm_access"] = map[string]any{"roles": "unrelated price-uploader"}
+	principal, err := authenticate(a, issuer.sign(kid, c))
+	if err != nil || !principal.HasScope(models.ScopePricesWrite) {
+		t.Errorf("roles in a string: %+v, %v", principal, err)
+	}
+}
+
+func TestJWTRejectsInvalidTokens(t *testing.T) {
+	a, issuer, _ := newTestAuthenticator(t)
+	kid := issuer.kid()
+
+	with := func(key string, value any) jwt.MapClaims {
+		c := claims("price-viewer")
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	for name, token := range map[string]string{
+		"bad signature":  signToken(t, testKey(t), kid, claims("price-viewer")),
+		"expired":        issuer.sign(kid, with("exp", time.Now().Add(-time.Hour).Unix())),
+		"no expiry":      issuer.sign(kid, with("exp", nil)),
+		"wrong issuer":   issuer.sign(kid, with("iss", "https://evil.example.com")),
+		"wrong audience": issuer.sign(kid, with("aud", "other-service")),
+		"no subject":     issuer.sign(kid, with("sub", nil)),
+		"no tenant":      issuer.sign(kid, with("tenant", nil)),
+		"not a jwt":      "garbage",
+	} {
+		if _, err := authenticate(a, token); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: Authenticate returned %v, want ErrInvalidCredentials", name, err)
+		}
+	}
+
+	// Admins may act across tenants, so their tokens need no tenant claim.
+	admin := claims("price-admin")
+	delete(admin, "tenant")
+	if principal, err := authenticate(a, issuer.sign(kid, admin)); err != nil || principal.Tenant != "" {
+		t.Errorf("admin without a tenant: %+v, %v", principal, err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v0/prices", nil)
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("request without a token: %v, want ErrMissingCredentials", err)
+	}
+}
+
+// TestJWTUnknownKidRefetches rotates the signing key and checks that a
+// token with the new kid makes the key set refetch the JWKS, but not more
+// often than minJWKSRefresh.
+func TestJWTUnknownKidRefetches(t *testing.T) {
+	a, issuer, keys := newTestAuthenticator(t)
+	if got := issuer.fetches(); got != 1 {
+		t.Fatalf("JWKS fetched %d times at startup, want 1", got)
+	}
+
+	rotated := issuer.addKey()
+	token := issuer.sign(rotated, claims("price-viewer"))
+
+	// Right after loading, an unknown kid does not trigger a refetch.
+	if _, err := authenticate(a, token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("token with a new kid right after loading: %v, want ErrInvalidCredentials", err)
+	}
+	if got := issuer.fetches(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", got)
+	}
+
+	keys.mu.Lock()
+	keys.attemptedAt = time.Now().Add(-minJWKSRefresh)
+	keys.mu.Unlock()
+
+	if _, err := authenticate(a, token); err != nil {
+		t.Fatalf("token with a new kid: %v", err)
+	}
+	if got := issuer.fetches(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+
+	// A kid the provider does not know either is rejected without another
+	// fetch.
+	unknown := signToken(t, testKey(t), "unknown", claims("price-viewer"))
+	if _, err := authenticate(a, unknown); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("token with an unknown kid: %v, want ErrInvalidCredentials", err)
+	}
+	if got := issuer.fetches(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
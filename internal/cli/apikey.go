@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"project_sem/internal/config"
+	"project_sem/internal/database"
+)
+
+const apiKeyUsage = `usage: app apikey <command>
+
+commands:
+  create -name <name> -scopes <scope,...> [-tenant <id>]
+                                            issue a key and print it once
+  list                                      list keys without their secrets
+  revoke <id>                               revoke a key
+
+scopes: prices:read, prices:write, admin`
+
+func APIKey(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("apikey: command is required\n%s", apiKeyUsage)
+	}
+	command, args := args[0], args[1:]
+
+	db, err := database.Connect(ctx, cfg.DB)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	keys := newApp(db, cfg).apiKeyService
+
+	var result any
+	switch command {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := flags.String("name", "", "name identifying the key owner")
+		scopes := flags.String("scopes", "", "comma-separated scopes")
+		tenantID := flags.String("tenant", "", "bind the key to a tenant, required unless scopes include admin")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if *name == "" {
+			return fmt.Errorf("apikey create: -name is required")
+		}
+
+		created, err := keys.Create(ctx, *name, *tenantID, splitList(*scopes))
+		if err != nil {
+			return fmt.Errorf("apikey create: %w", err)
+		}
+		result = created
+	case "list":
+		list, err := keys.List(ctx, "")
+		if err != nil {
+			return fmt.Errorf("apikey list: %w", err)
+		}
+		result = list
+	case "revoke":
+		if len(args) != 1 {
+			return fmt.Errorf("apikey revoke: exactly one id is required")
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("apikey revoke: invalid id %q", args[0])
+		}
+		revoked, err := keys.Revoke(ctx, id, "")
+		if err != nil {
+			return fmt.Errorf("apikey revoke: %w", err)
+		}
+		if !revoked {
+			return fmt.Errorf("apikey revoke: no active key with id %d", id)
+		}
+		result = map[string]any{"id": id, "revoked": true}
+	default:
+		return fmt.Errorf("apikey: unknown command %q\n%s", command, apiKeyUsage)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"project_sem/internal/config"
+	"project_sem/internal/logging"
+	"project_sem/internal/repository"
+	"project_sem/internal/services"
+	"project_sem/internal/tenant"
+)
+
+const usage = `usage: app [global flags] <command> [arguments]
+
+commands:
+  serve      start the HTTP server (default)
+  migrate    apply or roll back database migrations
+  import     load prices from an archive file
+  export     write filtered prices to a zip archive
+  stats      print statistics for the prices table
+  apikey     create, list or revoke API keys
+  token      issue local JWTs for development and tests
+  webhook    receive webhooks locally for development and tests
+  config     print the effective configuration
+
+Run "app -h" to list the global flags.`
+
+func Run(args []string) error {
+	cfg, args, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Println(usage)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	command := "serve"
+	if len(args) > 0 {
+		command = args[0]
+		args = args[1:]
+	}
+
+	switch command {
+	case "help":
+		fmt.Println(usage)
+		return nil
+	case "config":
+		return PrintConfig(cfg, args)
+	case "token":
+		return Token(cfg, args)
+	case "webhook":
+		return Webhook(args)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch command {
+	case "serve":
+		return Serve(ctx, cfg)
+	case "migrate":
+		return Migrate(ctx, cfg, args)
+	case "import":
+		return Import(ctx, cfg, args)
+	case "export":
+		return Export(ctx, cfg, args)
+	case "stats":
+		return Stats(ctx, cfg, args)
+	case "apikey":
+		return APIKey(ctx, cfg, args)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", command, usage)
+	}
+}
+
+type app struct {
+	archiveService *services.ArchiveService
+	csvService     *services.CSVService
+	importService  *services.ImportService
+	apiKeyService  *services.APIKeyService
+	webhookService *services.WebhookService
+	repo           *repository.PriceRepository
+	batchRepo      *repository.BatchRepository
+}
+
+func newApp(db *sql.DB, cfg *config.Config) *app {
+	repo := repository.NewPriceRepository(db)
+	archiveService := services.NewArchiveService(archiveLimits(cfg.Limits))
+	csvService := services.NewCSVService()
+	validatorService := services.NewValidatorService()
+	webhookService := services.NewWebhookService(repository.NewWebhookRepository(db), services.WebhookOptions{
+		Timeout:       cfg.Webhooks.Timeout,
+		MaxAttempts:   cfg.Webhooks.MaxAttempts,
+		RetryInitial:  cfg.Webhooks.RetryInitial,
+		RetryMaxDelay: cfg.Webhooks.RetryMaxDelay,
+		Retention:     cfg.Webhooks.Retention,
+	})
+
+	return &app{
+		archiveService: archiveService,
+		csvService:     csvService,
+		importService:  services.NewImportService(archiveService, csvService, validatorService, repo, webhookService),
+		apiKeyService:  services.NewAPIKeyService(repository.NewAPIKeyRepository(db)),
+		webhookService: webhookService,
+		repo:           repo,
+		batchRepo:      repository.NewBatchRepository(db),
+	}
+}
+
+func archiveLimits(cfg config.LimitsConfig) services.ArchiveLimits {
+	return services.ArchiveLimits{
+		MaxArchiveBytes:   int64(cfg.MaxArchiveSizeMB) << 20,
+		MaxEntryBytes:     int64(cfg.MaxEntrySizeMB) << 20,
+		MaxExtractedBytes: int64(cfg.MaxExtractedSizeMB) << 20,
+		MaxRatio:          cfg.MaxCompressionRatio,
+		MaxEntries:        cfg.MaxArchiveEntries,
+	}
+}
+
+func withTenant(ctx context.Context, id string) (context.Context, error) {
+	if !tenant.Valid(id) {
+		return nil, fmt.Errorf("invalid tenant %q", id)
+	}
+	return tenant.WithTenant(ctx, id), nil
+}
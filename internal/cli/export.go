@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"project_sem/internal/config"
+	"project_sem/internal/database"
+	"project_sem/internal/repository"
+)
+
+func Export(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	start := flags.String("start", "", "start date, YYYY-MM-DD")
+	end := flags.String("end", "", "end date, YYYY-MM-DD")
+	minStr := flags.String("min", "", "minimum price")
+	maxStr := flags.String("max", "", "maximum price")
+	output := flags.String("o", "data.zip", "output zip file")
+	tenantID := flags.String("tenant", cfg.Tenant.Default, "tenant to export")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, err := withTenant(ctx, *tenantID)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	var filter repository.PriceFilter
+
+	if *start != "" {
+		filter.StartDate = start
+	}
+
+	if *end != "" {
+		filter.EndDate = end
+	}
+
+	if *minStr != "" {
+		minPrice, err := strconv.ParseFloat(*minStr, 64)
+		if err != nil {
+			return fmt.Errorf("export: invalid min %q", *minStr)
+		}
+		filter.MinPrice = &minPrice
+	}
+
+	if *maxStr != "" {
+		maxPrice, err := strconv.ParseFloat(*maxStr, 64)
+		if err != nil {
+			return fmt.Errorf("export: invalid max %q", *maxStr)
+		}
+		filter.MaxPrice = &maxPrice
+	}
+
+	db, err := database.Connect(ctx, cfg.DB)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	a := newApp(db, cfg)
+
+	prices, err := a.repo.GetFilteredPrices(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	csvData, err := a.csvService.Generate(ctx, prices)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	zipData, err := a.archiveService.CreateZip(ctx, csvData, "data.csv")
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	if err := os.WriteFile(*output, zipData, 0o644); err != nil {
+		return fmt.Errorf("export: failed to write %s: %w", *output, err)
+	}
+
+	slog.Info("Export completed", "rows", len(prices), "output", *output)
+	return nil
+}
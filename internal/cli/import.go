@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"project_sem/internal/config"
+	"project_sem/internal/database"
+	"project_sem/internal/models"
+)
+
+func Import(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	archiveType := flags.String("type", "", "archive type: zip or tar (detected from the file extension by default)")
+	modeFlag := flags.String("mode", string(models.UploadModeInsertOnly), "upload mode: insert_only, upsert or replace")
+	strategyFlag := flags.String("id-strategy", string(models.IDStrategyPreserve), "id strategy: preserve or generate")
+	tenantID := flags.String("tenant", cfg.Tenant.Default, "tenant to import into")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: app import [flags] <file>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("import: exactly one file is required")
+	}
+	path := flags.Arg(0)
+
+	ctx, err := withTenant(ctx, *tenantID)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	if *archiveType == "" {
+		*archiveType = "zip"
+		if strings.EqualFold(filepath.Ext(path), ".tar") {
+			*archiveType = "tar"
+		}
+	}
+	if *archiveType != "zip" && *archiveType != "tar" {
+		return fmt.Errorf("import: invalid archive type %q", *archiveType)
+	}
+
+	mode, ok := models.ParseUploadMode(*modeFlag)
+	if !ok {
+		return fmt.Errorf("import: invalid mode %q", *modeFlag)
+	}
+	idStrategy, ok := models.ParseIDStrategy(*strategyFlag)
+	if !ok {
+		return fmt.Errorf("import: invalid id strategy %q", *strategyFlag)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("import: failed to read file: %w", err)
+	}
+
+	db, err := database.Connect(ctx, cfg.DB)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	a := newApp(db, cfg)
+
+	opts := models.ImportOptions{
+		Mode:       mode,
+		IDStrategy: idStrategy,
+		Subject:    "cli",
+		Source:     "file:" + path,
+	}
+
+	response, err := a.importService.Import(ctx, data, *archiveType, opts)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(response)
+}
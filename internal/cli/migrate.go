@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"project_sem/internal/config"
+	"project_sem/internal/database"
+)
+
+const migrateUsage = `usage: app migrate [command]
+
+commands:
+  up              apply all pending migrations (default)
+  to <version>    migrate up or down to the given version
+  down [steps]    roll back the given number of migrations (default 1)
+  version         print the current schema version`
+
+func Migrate(ctx context.Context, cfg *config.Config, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+		args = args[1:]
+	}
+
+	db, err := database.Connect(ctx, cfg.DB)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		if err := migrator.Up(ctx); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) != 1 {
+			return fmt.Errorf("migrate to: version is required\n%s", migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate to: invalid version %q", args[0])
+		}
+		if err := migrator.MigrateTo(ctx, version); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			steps, err = strconv.Atoi(args[0])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("migrate down: invalid steps %q", args[0])
+			}
+		}
+		if err := migrator.Rollback(ctx, steps); err != nil {
+			return err
+		}
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("Schema version", "version", version, "latest", database.LatestVersion())
+
+	return nil
+}
@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"project_sem/internal/auth"
+	"project_sem/internal/config"
+	"project_sem/internal/database"
+	"project_sem/internal/events"
+	"project_sem/internal/handlers"
+	"project_sem/internal/limits"
+	"project_sem/internal/metrics"
+	"project_sem/internal/middleware"
+	"project_sem/internal/models"
+	"project_sem/internal/quarantine"
+	"project_sem/internal/repository"
+	"project_sem/internal/services"
+	"project_sem/internal/storage"
+	"project_sem/internal/tracing"
+	"project_sem/internal/version"
+	"project_sem/internal/watcher"
+)
+
+func Serve(ctx context.Context, cfg *config.Config) error {
+	slog.Info("Starting server",
+		"version", version.Version, "commit", version.Commit, "build_date", version.BuildDate)
+	if cfg.DB.URL != "" {
+		slog.Info("Configuration loaded",
+			"db_url", cfg.Redacted().DB.URL, "db_max_open_conns", cfg.DB.MaxOpenConns, "server_port", cfg.Server.Port)
+	} else {
+		slog.Info("Configuration loaded",
+			"db_host", cfg.DB.Host, "db_port", cfg.DB.Port, "db_name", cfg.DB.Database, "db_sslmode", cfg.DB.SSLMode,
+			"db_max_open_conns", cfg.DB.MaxOpenConns, "server_port", cfg.Server.Port)
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	db, err := database.Open(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("Failed to close database connections", "error", err)
+			return
+		}
+		slog.Info("Database connections closed")
+	}()
+
+	metrics.RegisterDB(db, cfg.DB.Database)
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	var quarantineStore *quarantine.Store
+	if cfg.Quarantine.Dir != "" {
+		quarantineStore, err = quarantine.NewStore(cfg.Quarantine.Dir, cfg.Quarantine.TTL)
+		if err != nil {
+			return err
+		}
+		go quarantineStore.RunPurge(ctx, min(cfg.Quarantine.TTL, time.Hour))
+	}
+
+	blobs, err := newBlobStore(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	var store storage.Storage
+	if blobs != nil {
+		store = blobs.Storage
+	}
+	fetcher := services.NewSourceFetcher(services.FetchOptions{
+		AllowedHosts: cfg.Import.URLHosts,
+		FileRoots:    cfg.Import.FileRoots,
+		Timeout:      cfg.Import.FetchTimeout,
+		MaxBytes:     int64(cfg.Limits.MaxArchiveSizeMB) << 20,
+	}, store)
+
+	a := newApp(db, cfg)
+	pricesHandler := handlers.NewPricesHandler(a.importService, a.archiveService, a.csvService, a.repo, quarantineStore, blobs, fetcher)
+	apiKeysHandler := handlers.NewAPIKeysHandler(a.apiKeyService)
+	batchesHandler := handlers.NewBatchesHandler(a.batchRepo)
+	webhooksHandler := handlers.NewWebhooksHandler(a.webhookService)
+	priceEvents := repository.NewPriceEventRepository(db)
+	notifier := events.NewNotifier()
+	priceEventsHandler := handlers.NewPriceEventsHandler(priceEvents, notifier, handlers.PriceEventsOptions{
+		PollInterval: cfg.Events.PollInterval,
+		Heartbeat:    cfg.Events.Heartbeat,
+	}, ctx.Done())
+	healthHandler := handlers.NewHealthHandler(db, migrator, cfg.Server.MinFreeDiskMB)
+
+	router := mux.NewRouter()
+	router.Use(middleware.RouteSpan)
+
+	ready := middleware.RequireReady(healthHandler.Started)
+	authenticator, err := newAuthenticator(ctx, cfg.Auth, a.apiKeyService)
+	if err != nil {
+		return err
+	}
+	authenticate := middleware.Authenticate(authenticator)
+	resolveTenant := middleware.Tenant(cfg.Tenant.Header, cfg.Tenant.Default)
+	protect := func(scope string, handler http.Handler) http.Handler {
+		return ready(authenticate(resolveTenant(middleware.RequireScope(scope)(handler))))
+	}
+	uploadTimeout := middleware.Timeout(cfg.Server.UploadTimeout)
+	uploadRate := middleware.RateLimit(limits.NewClientLimiter(cfg.Limits.UploadRatePerMinute, cfg.Limits.UploadBurst))
+	importSlots := limits.NewSemaphore(cfg.Limits.MaxConcurrentImports, cfg.Limits.ImportQueueTimeout)
+	importLimit := middleware.ConcurrencyLimit(importSlots)
+	exportTimeout := middleware.Timeout(cfg.Server.ExportTimeout)
+
+	router.Handle("/api/v0/prices", protect(models.ScopePricesWrite, uploadRate(importLimit(uploadTimeout(http.HandlerFunc(pricesHandler.HandlePost)))))).Methods("POST")
+	router.Handle("/api/v0/prices/import", protect(models.ScopePricesWrite, uploadRate(importLimit(uploadTimeout(http.HandlerFunc(pricesHandler.HandleImportURL)))))).Methods("POST")
+	router.Handle("/api/v0/prices", protect(models.ScopePricesRead, exportTimeout(http.HandlerFunc(pricesHandler.HandleGet)))).Methods("GET")
+	router.Handle("/api/v0/prices/events", protect(models.ScopePricesRead, http.HandlerFunc(priceEventsHandler.HandleStream))).Methods("GET")
+	router.Handle("/api/v0/prices/{id:[0-9]+}", protect(models.ScopeAdmin, http.HandlerFunc(pricesHandler.HandleDelete))).Methods("DELETE")
+
+	router.Handle("/api/v0/batches", protect(models.ScopePricesRead, http.HandlerFunc(batchesHandler.HandleList))).Methods("GET")
+	router.Handle("/api/v0/batches/{id:[0-9]+}", protect(models.ScopePricesRead, http.HandlerFunc(batchesHandler.HandleGet))).Methods("GET")
+	router.Handle("/api/v0/batches/{id:[0-9]+}", protect(models.ScopeAdmin, http.HandlerFunc(batchesHandler.HandleRollback))).Methods("DELETE")
+
+	router.Handle("/api/v0/admin/api-keys", protect(models.ScopeAdmin, http.HandlerFunc(apiKeysHandler.HandleCreate))).Methods("POST")
+	router.Handle("/api/v0/admin/api-keys", protect(models.ScopeAdmin, http.HandlerFunc(apiKeysHandler.HandleList))).Methods("GET")
+	router.Handle("/api/v0/admin/api-keys/{id:[0-9]+}", protect(models.ScopeAdmin, http.HandlerFunc(apiKeysHandler.HandleRevoke))).Methods("DELETE")
+
+	router.Handle("/api/v0/admin/webhooks", protect(models.ScopeAdmin, http.HandlerFunc(webhooksHandler.HandleCreate))).Methods("POST")
+	router.Handle("/api/v0/admin/webhooks", protect(models.ScopeAdmin, http.HandlerFunc(webhooksHandler.HandleList))).Methods("GET")
+	router.Handle("/api/v0/admin/webhooks/{id:[0-9]+}", protect(models.ScopeAdmin, http.HandlerFunc(webhooksHandler.HandleDelete))).Methods("DELETE")
+	router.Handle("/api/v0/admin/webhooks/{id:[0-9]+}/deliveries", protect(models.ScopeAdmin, http.HandlerFunc(webhooksHandler.HandleDeliveries))).Methods("GET")
+
+	if quarantineStore != nil {
+		quarantineHandler := handlers.NewQuarantineHandler(quarantineStore, a.importService)
+		router.Handle("/api/v0/admin/quarantine", protect(models.ScopeAdmin, http.HandlerFunc(quarantineHandler.HandleList))).Methods("GET")
+		router.Handle("/api/v0/admin/quarantine/{id}", protect(models.ScopeAdmin, http.HandlerFunc(quarantineHandler.HandleDownload))).Methods("GET")
+		router.Handle("/api/v0/admin/quarantine/{id}", protect(models.ScopeAdmin, http.HandlerFunc(quarantineHandler.HandleDelete))).Methods("DELETE")
+		router.Handle("/api/v0/admin/quarantine/{id}/reprocess", protect(models.ScopeAdmin, uploadRate(importLimit(uploadTimeout(http.HandlerFunc(quarantineHandler.HandleReprocess)))))).Methods("POST")
+	}
+
+	if blobs != nil {
+		downloadsHandler := handlers.NewDownloadsHandler(blobs)
+		router.Handle("/api/v0/downloads/{token}", ready(http.HandlerFunc(downloadsHandler.HandleDownload))).Methods("GET")
+	}
+
+	router.HandleFunc("/healthz", healthHandler.HandleHealthz).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.HandleReadyz).Methods("GET")
+	router.HandleFunc("/version", healthHandler.HandleVersion).Methods("GET")
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", cfg.Server.Port),
+		Handler: otelhttp.NewHandler(middleware.RequestID(middleware.Metrics(router)), "http.server"),
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	startupErr := make(chan error, 1)
+	go func() {
+		startupErr <- prepareDatabase(ctx, cfg, db, migrator, healthHandler)
+	}()
+
+	// watcherDone is closed once the directory watcher has stopped, so that
+	// an import it is running is rolled back before the database is closed.
+	// webhooksDone likewise waits for deliveries being recorded.
+	var watcherDone, webhooksDone chan struct{}
+
+	var runErr error
+	for waiting := true; waiting; {
+		select {
+		case err := <-serverErr:
+			return fmt.Errorf("server failed to start: %w", err)
+		case err := <-startupErr:
+			if err != nil {
+				if ctx.Err() == nil {
+					runErr = err
+				}
+				waiting = false
+				break
+			}
+			healthHandler.MarkStarted()
+			slog.Info("Service is ready")
+
+			go func() {
+				if err := notifier.Listen(ctx, database.DSN(cfg.DB)); err != nil {
+					slog.Error("Failed to listen for price events, streams fall back to polling", "error", err)
+				}
+			}()
+			go events.RunPurge(ctx, priceEvents, cfg.Events.Retention, time.Hour)
+
+			webhooksDone = make(chan struct{})
+			go func() {
+				defer close(webhooksDone)
+				a.webhookService.Run(ctx, cfg.Webhooks.PollInterval)
+			}()
+
+			if cfg.Import.WatchDir != "" {
+				dirWatcher, err := newWatcher(cfg, a.importService, importSlots, store)
+				if err != nil {
+					runErr = err
+					waiting = false
+					break
+				}
+				watcherDone = make(chan struct{})
+				go func() {
+					defer close(watcherDone)
+					dirWatcher.Run(ctx)
+				}()
+			}
+		case <-ctx.Done():
+			waiting = false
+		}
+	}
+
+	if runErr != nil {
+		slog.Error("Startup failed, stopping server", "error", runErr)
+	} else {
+		slog.Info("Shutdown signal received, draining in-flight requests", "timeout", cfg.Server.ShutdownTimeout.String())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Drain timeout exceeded, closing remaining connections", "error", err)
+		server.Close()
+	} else {
+		slog.Info("All in-flight requests completed")
+	}
+
+	if watcherDone != nil {
+		<-watcherDone
+	}
+	if webhooksDone != nil {
+		<-webhooksDone
+	}
+
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+
+	return runErr
+}
+
+func newAuthenticator(ctx context.Context, cfg config.AuthConfig, keys *services.APIKeyService) (auth.Authenticator, error) {
+	switch cfg.Mode {
+	case "apikey":
+		return auth.NewAPIKeyAuthenticator(keys), nil
+	case "jwt":
+		keySet, err := auth.NewKeySet(ctx, cfg.JWKS, cfg.JWKSRefresh)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewJWTAuthenticator(keySet, cfg), nil
+	default:
+		slog.Warn("Authentication is disabled, every client has full access", "auth_mode", cfg.Mode)
+		return auth.Anonymous{}, nil
+	}
+}
+
+// prepareDatabase runs in the background while the HTTP server is already
+// answering /readyz with "not ready", and logs the last error. It starts over
+// until it succeeds or ctx is done, unless DB_RETRY_EXIT asks to give up
+// after the first DB_RETRY_MAX_WAIT.
+func prepareDatabase(ctx context.Context, cfg *config.Config, db *sql.DB, migrator *database.Migrator, health *handlers.HealthHandler) error {
+	for {
+		err := prepareDatabaseOnce(ctx, cfg, db, migrator, health)
+		if err == nil || ctx.Err() != nil || cfg.DB.RetryExit {
+			return err
+		}
+
+		health.SetStartupError(err)
+		slog.Error("Database is not ready, retrying in the background", "retry_in", cfg.DB.RetryMaxDelay.String(), "error", err)
+
+		timer := time.NewTimer(cfg.DB.RetryMaxDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func prepareDatabaseOnce(ctx context.Context, cfg *config.Config, db *sql.DB, migrator *database.Migrator, health *handlers.HealthHandler) error {
+	if err := database.WaitForConnection(ctx, db, cfg.DB, health.SetStartupError); err != nil {
+		return err
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	slog.Info("Database migrations completed")
+
+	if err := database.SetRowLevelSecurity(ctx, db, cfg.Tenant.RLS); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newBlobStore returns nil when no storage backend is configured. Stored
+// exports outlive the links to them by one link TTL and are purged after.
+func newBlobStore(ctx context.Context, cfg config.StorageConfig) (*handlers.BlobStore, error) {
+	store, err := storage.New(ctx, cfg)
+	if err != nil || store == nil {
+		return nil, err
+	}
+
+	if cfg.LinkSecret == "" {
+		slog.Warn("STORAGE_LINK_SECRET is not set, download links are valid only for this process")
+	}
+	links, err := storage.NewLinks(cfg.LinkSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	go storage.RunPurge(ctx, store, "exports/", 2*cfg.LinkTTL, min(cfg.LinkTTL, time.Hour))
+
+	slog.Info("Blob storage enabled", "backend", cfg.Backend)
+	return &handlers.BlobStore{
+		Storage:           store,
+		Links:             links,
+		LinkTTL:           cfg.LinkTTL,
+		ExportLinkMinSize: int64(cfg.ExportLinkMinSizeMB) << 20,
+	}, nil
+}
+
+func newWatcher(cfg *config.Config, imports *services.ImportService, slots *limits.Semaphore, store storage.Storage) (*watcher.Watcher, error) {
+	tenantID := cfg.Import.WatchTenant
+	if tenantID == "" {
+		tenantID = cfg.Tenant.Default
+	}
+	mode, _ := models.ParseUploadMode(cfg.Import.WatchMode)
+	idStrategy, _ := models.ParseIDStrategy(cfg.Import.WatchIDStrategy)
+
+	return watcher.New(watcher.Options{
+		Dir:        cfg.Import.WatchDir,
+		Interval:   cfg.Import.WatchInterval,
+		Notify:     cfg.Import.WatchNotify,
+		Settle:     cfg.Import.WatchSettle,
+		Tenant:     tenantID,
+		Mode:       mode,
+		IDStrategy: idStrategy,
+	}, imports, slots, store)
+}
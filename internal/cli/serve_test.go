@@ -0,0 +1,375 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	_ "github.com/lib/pq"
+	"project_sem/internal/auth"
+	"project_sem/internal/config"
+	"project_sem/internal/models"
+	"project_sem/internal/repository"
+	"project_sem/internal/services"
+	"project_sem/internal/testutil"
+)
+
+// testConfig returns the configuration of a server on a free port using
+// the database in TEST_DATABASE_URL, and skips the test without one. Every
+// test acts on a tenant of its own.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	dsn := testutil.DSN(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cfg := config.Default()
+	cfg.DB.URL = dsn
+	cfg.Server.Port = fmt.Sprint(port)
+	cfg.Server.ShutdownTimeout = 30 * time.Second
+	cfg.Auth.Mode = "none"
+	cfg.Auth.AllowAnonymous = true
+	cfg.Tenant.Default = testutil.TenantID()
+	return cfg
+}
+
+func testArchive(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("data.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "id,name,category,price,create_date\n"+
+		"1,item1,cat1,100,2024-01-01\n"+
+		"2,item2,cat2,200,2024-01-15\n"+
+		"3,item3,cat3,300,2024-01-20\n")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func waitReady(t *testing.T, baseURL string) {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(baseURL + "/readyz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("server did not become ready")
+}
+
+// TestServeDrainsUploadsOnShutdown starts an upload whose body arrives
+// slowly, stops the server while it is in flight and checks that the
+// upload still completes and its rows are committed.
+func TestServeDrainsUploadsOnShutdown(t *testing.T) {
+	cfg := testConfig(t)
+	baseURL := "http://127.0.0.1:" + cfg.Server.Port
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, cfg)
+	}()
+	waitReady(t, baseURL)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, err := mw.CreateFormFile("file", "data.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(testArchive(t))
+	mw.Close()
+	body := form.Bytes()
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/v0/prices", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.ContentLength = int64(len(body))
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		done <- result{resp, err}
+	}()
+
+	half := len(body) / 2
+	if _, err := pw.Write(body[:half]); err != nil {
+		t.Fatalf("failed to send the first half of the upload: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// The same as receiving SIGTERM.
+	stop()
+	time.Sleep(500 * time.Millisecond)
+
+	if _, err := pw.Write(body[half:]); err != nil {
+		t.Fatalf("failed to send the rest of the upload: %v", err)
+	}
+	pw.Close()
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("upload failed: %v", res.err)
+	}
+	defer res.resp.Body.Close()
+	if res.resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(res.resp.Body)
+		t.Fatalf("upload returned %d: %s", res.resp.StatusCode, data)
+	}
+	var response models.UploadResponse
+	if err := json.NewDecoder(res.resp.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode upload response: %v", err)
+	}
+	if response.InsertedCount != 3 {
+		t.Errorf("inserted_count = %d, want 3", response.InsertedCount)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Serve returned an error: %v", err)
+		}
+	case <-time.After(cfg.Server.ShutdownTimeout):
+		t.Fatal("Serve did not return after shutdown")
+	}
+
+	db, err := sql.Open("postgres", cfg.DB.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM prices WHERE tenant_id = $1", cfg.Tenant.Default).Scan(&count); err != nil {
+		t.Fatalf("failed to count committed rows: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("committed rows = %d, want 3", count)
+	}
+}
+
+// TestServeEnforcesRoles serves the API with bearer tokens and checks that
+// each route requires the scope of its role: viewers may only read,
+// uploaders may not delete prices or roll back batches.
+func TestServeEnforcesRoles(t *testing.T) {
+	cfg := testConfig(t)
+	baseURL := "http://127.0.0.1:" + cfg.Server.Port
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := auth.RSAPublicJWK(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(auth.JWKS{Keys: []auth.JWK{jwk}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Auth.Mode = "jwt"
+	cfg.Auth.JWKS = filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(cfg.Auth.JWKS, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, cfg)
+	}()
+	defer func() {
+		stop()
+		<-served
+	}()
+	waitReady(t, baseURL)
+
+	tokens := make(map[string]string)
+	for _, role := range []string{"viewer", "uploader", "admin"} {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub":    role,
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"tenant": cfg.Tenant.Default,
+			"roles":  []string{role},
+		})
+		token.Header["kid"] = jwk.Kid
+		tokens[role], err = token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		role, method, path string
+		status             int
+	}{
+		{"viewer", http.MethodGet, "/api/v0/prices", http.StatusOK},
+		{"viewer", http.MethodPost, "/api/v0/prices", http.StatusForbidden},
+		{"viewer", http.MethodPost, "/api/v0/prices/import", http.StatusForbidden},
+		{"uploader", http.MethodDelete, "/api/v0/prices/1", http.StatusForbidden},
+		{"uploader", http.MethodDelete, "/api/v0/batches/1", http.StatusForbidden},
+		{"uploader", http.MethodGet, "/api/v0/admin/api-keys", http.StatusForbidden},
+		{"admin", http.MethodDelete, "/api/v0/batches/0", http.StatusNotFound},
+		{"", http.MethodGet, "/api/v0/prices", http.StatusUnauthorized},
+	} {
+		headers := map[string]string{}
+		if tc.role != "" {
+			headers["Authorization"] = "Bearer " + tokens[tc.role]
+		}
+		resp, _ := doRequest(t, tc.method, baseURL+tc.path, nil, headers)
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s as %q returned %d, want %d", tc.method, tc.path, tc.role, resp.StatusCode, tc.status)
+		}
+	}
+}
+
+// TestServeIsolatesTenants uploads prices to one tenant with an admin key
+// and checks that a key bound to another tenant can neither select that
+// tenant nor see its prices.
+func TestServeIsolatesTenants(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Auth.Mode = "apikey"
+	baseURL := "http://127.0.0.1:" + cfg.Server.Port
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, cfg)
+	}()
+	defer func() {
+		stop()
+		<-served
+	}()
+	waitReady(t, baseURL)
+
+	db, err := sql.Open("postgres", cfg.DB.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	keys := services.NewAPIKeyService(repository.NewAPIKeyRepository(db))
+	admin, err := keys.Create(ctx, "admin", "", []string{models.ScopeAdmin})
+	if err != nil {
+		t.Fatalf("failed to create admin key: %v", err)
+	}
+	reader, err := keys.Create(ctx, "reader", cfg.Tenant.Default, []string{models.ScopePricesRead})
+	if err != nil {
+		t.Fatalf("failed to create tenant key: %v", err)
+	}
+
+	other := testutil.TenantID()
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, err := mw.CreateFormFile("file", "data.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(testArchive(t))
+	mw.Close()
+	resp, _ := doRequest(t, http.MethodPost, baseURL+"/api/v0/prices", &form, map[string]string{
+		"Content-Type":    mw.FormDataContentType(),
+		auth.APIKeyHeader: admin.Key,
+		cfg.Tenant.Header: other,
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload to tenant %s returned %d", other, resp.StatusCode)
+	}
+
+	resp, _ = doRequest(t, http.MethodGet, baseURL+"/api/v0/prices", nil, map[string]string{
+		auth.APIKeyHeader: reader.Key,
+		cfg.Tenant.Header: other,
+	})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("reading tenant %s with a key bound to %s returned %d, want 403", other, cfg.Tenant.Default, resp.StatusCode)
+	}
+
+	resp, body := doRequest(t, http.MethodGet, baseURL+"/api/v0/prices", nil, map[string]string{
+		auth.APIKeyHeader: reader.Key,
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("reading the key's own tenant returned %d", resp.StatusCode)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("export is not a zip archive: %v", err)
+	}
+	if len(zr.File) != 1 {
+		t.Fatalf("export has %d files, want 1", len(zr.File))
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines > 1 {
+		t.Errorf("export of tenant %s has %d rows of tenant %s:\n%s", cfg.Tenant.Default, lines-1, other, data)
+	}
+}
+
+// doRequest sends a request with the given headers and returns the response
+// with its body read.
+func doRequest(t *testing.T, method, url string, body io.Reader, headers map[string]string) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: failed to read the response: %v", method, url, err)
+	}
+	return resp, data
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"project_sem/internal/config"
+	"project_sem/internal/database"
+	"project_sem/internal/repository"
+)
+
+func Stats(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	tenantID := flags.String("tenant", cfg.Tenant.Default, "tenant to report on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, err := withTenant(ctx, *tenantID)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	db, err := database.Connect(ctx, cfg.DB)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	stats, err := repository.NewPriceRepository(db).GetStatistics(ctx)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"project_sem/internal/auth"
+	"project_sem/internal/config"
+)
+
+const tokenUsage = `usage: app token <command>
+
+A local stand-in for the identity provider, for development and tests.
+
+commands:
+  keygen -key <file> -jwks <file>        create an RSA signing key and its JWKS
+  sign -key <file> -sub <subject> -roles <role,...> [-tenant <id>] [-ttl 1h]
+                                         print a signed token for AUTH_MODE=jwt`
+
+func Token(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("token: command is required\n%s", tokenUsage)
+	}
+
+	switch args[0] {
+	case "keygen":
+		return tokenKeygen(args[1:])
+	case "sign":
+		return tokenSign(cfg.Auth, args[1:])
+	default:
+		return fmt.Errorf("token: unknown command %q\n%s", args[0], tokenUsage)
+	}
+}
+
+func tokenKeygen(args []string) error {
+	flags := flag.NewFlagSet("token keygen", flag.ContinueOnError)
+	keyPath := flags.String("key", "dev-key.pem", "where to write the private key")
+	jwksPath := flags.String("jwks", "dev-jwks.json", "where to write the public JWKS")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("token keygen: failed to generate key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("token keygen: failed to encode key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(*keyPath, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("token keygen: %w", err)
+	}
+
+	jwk, err := auth.RSAPublicJWK(&key.PublicKey)
+	if err != nil {
+		return fmt.Errorf("token keygen: %w", err)
+	}
+	jwks, err := json.MarshalIndent(auth.JWKS{Keys: []auth.JWK{jwk}}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("token keygen: %w", err)
+	}
+	if err := os.WriteFile(*jwksPath, append(jwks, '\n'), 0o644); err != nil {
+		return fmt.Errorf("token keygen: %w", err)
+	}
+
+	fmt.Printf("private key: %s\nJWKS: %s (kid %s)\n", *keyPath, *jwksPath, jwk.Kid)
+	return nil
+}
+
+func tokenSign(cfg config.AuthConfig, args []string) error {
+	flags := flag.NewFlagSet("token sign", flag.ContinueOnError)
+	keyPath := flags.String("key", "dev-key.pem", "private key created by token keygen")
+	subject := flags.String("sub", "", "token subject")
+	roles := flags.String("roles", "", "comma-separated values of the roles claim")
+	issuer := flags.String("iss", cfg.Issuer, "token issuer")
+	audience := flags.String("aud", cfg.Audience, "token audience")
+	ttl := flags.Duration("ttl", time.Hour, "token lifetime")
+	tenantID := flags.String("tenant", "", "bind the token to a tenant")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *subject == "" {
+		return errors.New("token sign: -sub is required")
+	}
+
+	keyPEM, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return fmt.Errorf("token sign: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return fmt.Errorf("token sign: %s is not a PEM file", *keyPath)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("token sign: failed to parse key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("token sign: only RSA keys are supported")
+	}
+
+	jwk, err := auth.RSAPublicJWK(&key.PublicKey)
+	if err != nil {
+		return fmt.Errorf("token sign: %w", err)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": *subject,
+		"iat": now.Unix(),
+		"exp": now.Add(*ttl).Unix(),
+	}
+	if *issuer != "" {
+		claims["iss"] = *issuer
+	}
+	if *audience != "" {
+		claims["aud"] = *audience
+	}
+	if *tenantID != "" && cfg.TenantClaim != "" {
+		claims[cfg.TenantClaim] = *tenantID
+	}
+	setNestedClaim(claims, strings.Split(cfg.RolesClaim, "."), splitList(*roles))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = jwk.Kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return fmt.Errorf("token sign: %w", err)
+	}
+
+	fmt.Println(signed)
+	return nil
+}
+
+func setNestedClaim(claims map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := claims[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			claims[key] = next
+		}
+		claims = next
+	}
+	claims[path[len(path)-1]] = value
+}
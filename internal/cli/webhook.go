@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"project_sem/internal/services"
+)
+
+const webhookUsage = `usage: app webhook <command>
+
+A local stand-in for a webhook receiver, for development and tests.
+
+commands:
+  receive -secret <secret> [-addr :9090] [-status 200] [-tolerance 5m]
+                                         print deliveries and check their signatures`
+
+func Webhook(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("webhook: command is required\n%s", webhookUsage)
+	}
+
+	switch args[0] {
+	case "receive":
+		return webhookReceive(args[1:])
+	default:
+		return fmt.Errorf("webhook: unknown command %q\n%s", args[0], webhookUsage)
+	}
+}
+
+func webhookReceive(args []string) error {
+	flags := flag.NewFlagSet("webhook receive", flag.ContinueOnError)
+	addr := flags.String("addr", ":9090", "address to listen on")
+	secret := flags.String("secret", "", "signing secret returned when the webhook was created")
+	status := flags.Int("status", http.StatusOK, "status to answer valid deliveries with, e.g. 500 to exercise retries")
+	tolerance := flags.Duration("tolerance", 5*time.Minute, "accepted clock difference of the signature timestamp")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *secret == "" {
+		return errors.New("webhook receive: -secret is required")
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		delivery := r.Header.Get(services.WebhookIDHeader)
+		event := r.Header.Get(services.WebhookEventHeader)
+		if err := services.VerifyWebhook(*secret, r.Header, body, time.Now(), *tolerance); err != nil {
+			slog.Warn("Rejected webhook", "delivery_id", delivery, "event", event, "error", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Write(body)
+		}
+		slog.Info("Received webhook", "delivery_id", delivery, "event", event, "answer", *status)
+		fmt.Fprintln(os.Stdout, pretty.String())
+
+		w.WriteHeader(*status)
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: *addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("Webhook receiver listening", "addr", *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("webhook receive: %w", err)
+	}
+	return nil
+}
@@ -1,42 +1,511 @@
 package config
 
-import "os"
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
 
 type DBConfig struct {
-	Host     string
-	Port     string
-	Database string
-	User     string
-	Password string
+	URL             string        `yaml:"url" toml:"url"`
+	Host            string        `yaml:"host" toml:"host"`
+	Port            string        `yaml:"port" toml:"port"`
+	Database        string        `yaml:"database" toml:"database"`
+	User            string        `yaml:"user" toml:"user"`
+	Password        string        `yaml:"password" toml:"password"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode"`
+	SSLRootCert     string        `yaml:"sslrootcert" toml:"sslrootcert"`
+	SSLCert         string        `yaml:"sslcert" toml:"sslcert"`
+	SSLKey          string        `yaml:"sslkey" toml:"sslkey"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	RetryInitial    time.Duration `yaml:"retry_initial" toml:"retry_initial"`
+	RetryMaxDelay   time.Duration `yaml:"retry_max_delay" toml:"retry_max_delay"`
+	RetryMaxWait    time.Duration `yaml:"retry_max_wait" toml:"retry_max_wait"`
+	RetryExit       bool          `yaml:"retry_exit" toml:"retry_exit"`
 }
 
 type ServerConfig struct {
-	Port string
+	Port            string        `yaml:"port" toml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	UploadTimeout   time.Duration `yaml:"upload_timeout" toml:"upload_timeout"`
+	ExportTimeout   time.Duration `yaml:"export_timeout" toml:"export_timeout"`
+	MinFreeDiskMB   uint64        `yaml:"min_free_disk_mb" toml:"min_free_disk_mb"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"`
+	File        string  `yaml:"file" toml:"file"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+type AuthConfig struct {
+	Mode           string        `yaml:"mode" toml:"mode"`
+	AllowAnonymous bool          `yaml:"allow_anonymous" toml:"allow_anonymous"`
+	JWKS           string        `yaml:"jwks" toml:"jwks"`
+	JWKSRefresh    time.Duration `yaml:"jwks_refresh" toml:"jwks_refresh"`
+	Issuer         string        `yaml:"issuer" toml:"issuer"`
+	Audience       string        `yaml:"audience" toml:"audience"`
+	RolesClaim     string        `yaml:"roles_claim" toml:"roles_claim"`
+	TenantClaim    string        `yaml:"tenant_claim" toml:"tenant_claim"`
+	ViewerRoles    []string      `yaml:"viewer_roles" toml:"viewer_roles"`
+	UploaderRoles  []string      `yaml:"uploader_roles" toml:"uploader_roles"`
+	AdminRoles     []string      `yaml:"admin_roles" toml:"admin_roles"`
+}
+
+type TenantConfig struct {
+	Header  string `yaml:"header" toml:"header"`
+	Default string `yaml:"default" toml:"default"`
+	RLS     bool   `yaml:"rls" toml:"rls"`
+}
+
+type LimitsConfig struct {
+	UploadRatePerMinute  float64       `yaml:"upload_rate_per_minute" toml:"upload_rate_per_minute"`
+	UploadBurst          int           `yaml:"upload_burst" toml:"upload_burst"`
+	MaxConcurrentImports int           `yaml:"max_concurrent_imports" toml:"max_concurrent_imports"`
+	ImportQueueTimeout   time.Duration `yaml:"import_queue_timeout" toml:"import_queue_timeout"`
+	MaxArchiveSizeMB     uint64        `yaml:"max_archive_size_mb" toml:"max_archive_size_mb"`
+	MaxEntrySizeMB       uint64        `yaml:"max_entry_size_mb" toml:"max_entry_size_mb"`
+	MaxExtractedSizeMB   uint64        `yaml:"max_extracted_size_mb" toml:"max_extracted_size_mb"`
+	MaxCompressionRatio  float64       `yaml:"max_compression_ratio" toml:"max_compression_ratio"`
+	MaxArchiveEntries    int           `yaml:"max_archive_entries" toml:"max_archive_entries"`
+}
+
+type QuarantineConfig struct {
+	Dir string        `yaml:"dir" toml:"dir"`
+	TTL time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+type StorageConfig struct {
+	Backend             string        `yaml:"backend" toml:"backend"`
+	Dir                 string        `yaml:"dir" toml:"dir"`
+	S3Endpoint          string        `yaml:"s3_endpoint" toml:"s3_endpoint"`
+	S3Region            string        `yaml:"s3_region" toml:"s3_region"`
+	S3Bucket            string        `yaml:"s3_bucket" toml:"s3_bucket"`
+	S3AccessKey         string        `yaml:"s3_access_key" toml:"s3_access_key"`
+	S3SecretKey         string        `yaml:"s3_secret_key" toml:"s3_secret_key"`
+	S3UseSSL            bool          `yaml:"s3_use_ssl" toml:"s3_use_ssl"`
+	LinkSecret          string        `yaml:"link_secret" toml:"link_secret"`
+	LinkTTL             time.Duration `yaml:"link_ttl" toml:"link_ttl"`
+	ExportLinkMinSizeMB uint64        `yaml:"export_link_min_size_mb" toml:"export_link_min_size_mb"`
+}
+
+type ImportConfig struct {
+	URLHosts     []string      `yaml:"url_hosts" toml:"url_hosts"`
+	FileRoots    []string      `yaml:"file_roots" toml:"file_roots"`
+	FetchTimeout time.Duration `yaml:"fetch_timeout" toml:"fetch_timeout"`
+
+	WatchDir        string        `yaml:"watch_dir" toml:"watch_dir"`
+	WatchInterval   time.Duration `yaml:"watch_interval" toml:"watch_interval"`
+	WatchNotify     bool          `yaml:"watch_notify" toml:"watch_notify"`
+	WatchSettle     time.Duration `yaml:"watch_settle" toml:"watch_settle"`
+	WatchTenant     string        `yaml:"watch_tenant" toml:"watch_tenant"`
+	WatchMode       string        `yaml:"watch_mode" toml:"watch_mode"`
+	WatchIDStrategy string        `yaml:"watch_id_strategy" toml:"watch_id_strategy"`
+}
+
+type WebhookConfig struct {
+	Timeout       time.Duration `yaml:"timeout" toml:"timeout"`
+	MaxAttempts   int           `yaml:"max_attempts" toml:"max_attempts"`
+	RetryInitial  time.Duration `yaml:"retry_initial" toml:"retry_initial"`
+	RetryMaxDelay time.Duration `yaml:"retry_max_delay" toml:"retry_max_delay"`
+	PollInterval  time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	Retention     time.Duration `yaml:"retention" toml:"retention"`
+}
+
+type EventsConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	Heartbeat    time.Duration `yaml:"heartbeat" toml:"heartbeat"`
+	Retention    time.Duration `yaml:"retention" toml:"retention"`
 }
 
 type Config struct {
-	DB     DBConfig
-	Server ServerConfig
+	DB         DBConfig         `yaml:"db" toml:"db"`
+	Server     ServerConfig     `yaml:"server" toml:"server"`
+	Log        LogConfig        `yaml:"log" toml:"log"`
+	Tracing    TracingConfig    `yaml:"tracing" toml:"tracing"`
+	Auth       AuthConfig       `yaml:"auth" toml:"auth"`
+	Tenant     TenantConfig     `yaml:"tenant" toml:"tenant"`
+	Limits     LimitsConfig     `yaml:"limits" toml:"limits"`
+	Quarantine QuarantineConfig `yaml:"quarantine" toml:"quarantine"`
+	Storage    StorageConfig    `yaml:"storage" toml:"storage"`
+	Import     ImportConfig     `yaml:"import" toml:"import"`
+	Webhooks   WebhookConfig    `yaml:"webhooks" toml:"webhooks"`
+	Events     EventsConfig     `yaml:"events" toml:"events"`
 }
 
-func LoadConfig() *Config {
+func Default() *Config {
 	return &Config{
 		DB: DBConfig{
-			Host:     getEnv("POSTGRES_HOST", "localhost"),
-			Port:     getEnv("POSTGRES_PORT", "5432"),
-			Database: getEnv("POSTGRES_DB", "project-sem-1"),
-			User:     getEnv("POSTGRES_USER", "validator"),
-			Password: getEnv("POSTGRES_PASSWORD", "val1dat0r"),
+			Host:            "localhost",
+			Port:            "5432",
+			Database:        "project-sem-1",
+			SSLMode:         "disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			RetryInitial:    500 * time.Millisecond,
+			RetryMaxDelay:   15 * time.Second,
+			RetryMaxWait:    2 * time.Minute,
 		},
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
+			Port:            "8080",
+			ShutdownTimeout: 25 * time.Second,
+			UploadTimeout:   5 * time.Minute,
+			ExportTimeout:   2 * time.Minute,
+			MinFreeDiskMB:   100,
 		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "prices-api",
+			SampleRatio: 1,
+		},
+		Auth: AuthConfig{
+			Mode:          "apikey",
+			JWKSRefresh:   time.Hour,
+			RolesClaim:    "roles",
+			TenantClaim:   "tenant",
+			ViewerRoles:   []string{"viewer"},
+			UploaderRoles: []string{"uploader"},
+			AdminRoles:    []string{"admin"},
+		},
+		Tenant: TenantConfig{
+			Header:  "X-Tenant-ID",
+			Default: "default",
+		},
+		Limits: LimitsConfig{
+			UploadRatePerMinute:  60,
+			UploadBurst:          10,
+			MaxConcurrentImports: 4,
+			ImportQueueTimeout:   30 * time.Second,
+			MaxArchiveSizeMB:     100,
+			MaxEntrySizeMB:       256,
+			MaxExtractedSizeMB:   512,
+			MaxCompressionRatio:  100,
+			MaxArchiveEntries:    1000,
+		},
+		Quarantine: QuarantineConfig{
+			TTL: 7 * 24 * time.Hour,
+		},
+		Storage: StorageConfig{
+			Backend:             "none",
+			S3UseSSL:            true,
+			LinkTTL:             time.Hour,
+			ExportLinkMinSizeMB: 10,
+		},
+		Import: ImportConfig{
+			FetchTimeout:    2 * time.Minute,
+			WatchInterval:   time.Minute,
+			WatchSettle:     10 * time.Second,
+			WatchMode:       "insert_only",
+			WatchIDStrategy: "preserve",
+		},
+		Webhooks: WebhookConfig{
+			Timeout:       10 * time.Second,
+			MaxAttempts:   8,
+			RetryInitial:  30 * time.Second,
+			RetryMaxDelay: time.Hour,
+			PollInterval:  10 * time.Second,
+			Retention:     30 * 24 * time.Hour,
+		},
+		Events: EventsConfig{
+			PollInterval: 5 * time.Second,
+			Heartbeat:    15 * time.Second,
+			Retention:    7 * 24 * time.Hour,
+		},
+	}
+}
+
+type field struct {
+	env   string
+	flag  string
+	usage string
+	set   func(cfg *Config, value string) error
+}
+
+var fields = []field{
+	{"DATABASE_URL", "", "", setString(func(c *Config) *string { return &c.DB.URL })},
+	{"POSTGRES_HOST", "db-host", "database host", setString(func(c *Config) *string { return &c.DB.Host })},
+	{"POSTGRES_PORT", "db-port", "database port", setString(func(c *Config) *string { return &c.DB.Port })},
+	{"POSTGRES_DB", "db-name", "database name", setString(func(c *Config) *string { return &c.DB.Database })},
+	{"POSTGRES_USER", "db-user", "database user", setString(func(c *Config) *string { return &c.DB.User })},
+	{"POSTGRES_PASSWORD", "", "", setString(func(c *Config) *string { return &c.DB.Password })},
+	{"POSTGRES_SSLMODE", "db-sslmode", "database sslmode: disable, require, verify-ca or verify-full", setString(func(c *Config) *string { return &c.DB.SSLMode })},
+	{"POSTGRES_SSLROOTCERT", "db-sslrootcert", "CA certificate used to verify the database server", setString(func(c *Config) *string { return &c.DB.SSLRootCert })},
+	{"POSTGRES_SSLCERT", "db-sslcert", "client certificate for the database", setString(func(c *Config) *string { return &c.DB.SSLCert })},
+	{"POSTGRES_SSLKEY", "db-sslkey", "client certificate key for the database", setString(func(c *Config) *string { return &c.DB.SSLKey })},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections, 0 for unlimited", setInt(func(c *Config) *int { return &c.DB.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", setInt(func(c *Config) *int { return &c.DB.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum lifetime of a database connection, 0 for unlimited", setDuration(func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum idle time of a database connection, 0 for unlimited", setDuration(func(c *Config) *time.Duration { return &c.DB.ConnMaxIdleTime })},
+	{"DB_RETRY_INITIAL", "db-retry-initial", "delay before the second database connection attempt", setDuration(func(c *Config) *time.Duration { return &c.DB.RetryInitial })},
+	{"DB_RETRY_MAX_DELAY", "db-retry-max-delay", "upper bound of the delay between database connection attempts", setDuration(func(c *Config) *time.Duration { return &c.DB.RetryMaxDelay })},
+	{"DB_RETRY_MAX_WAIT", "db-retry-max-wait", "total time to wait for the database at startup, 0 to wait forever", setDuration(func(c *Config) *time.Duration { return &c.DB.RetryMaxWait })},
+	{"DB_RETRY_EXIT", "db-retry-exit", "stop the server when the database is not ready after DB_RETRY_MAX_WAIT instead of retrying in the background", setBool(func(c *Config) *bool { return &c.DB.RetryExit })},
+	{"SERVER_PORT", "port", "HTTP server port", setString(func(c *Config) *string { return &c.Server.Port })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time to drain in-flight requests on shutdown", setDuration(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+	{"UPLOAD_TIMEOUT", "upload-timeout", "timeout of POST /api/v0/prices", setDuration(func(c *Config) *time.Duration { return &c.Server.UploadTimeout })},
+	{"EXPORT_TIMEOUT", "export-timeout", "timeout of GET /api/v0/prices", setDuration(func(c *Config) *time.Duration { return &c.Server.ExportTimeout })},
+	{"MIN_FREE_DISK_MB", "min-free-disk-mb", "free disk space required by /readyz", setUint(func(c *Config) *uint64 { return &c.Server.MinFreeDiskMB })},
+	{"LOG_LEVEL", "log-level", "log level: debug, info, warn or error", setString(func(c *Config) *string { return &c.Log.Level })},
+	{"LOG_FORMAT", "log-format", "log format: json or text", setString(func(c *Config) *string { return &c.Log.Format })},
+	{"TRACING_EXPORTER", "tracing-exporter", "trace exporter: none, otlp or stdout", setString(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "tracing-endpoint", "OTLP/HTTP traces endpoint URL", setString(func(c *Config) *string { return &c.Tracing.Endpoint })},
+	{"TRACING_FILE", "tracing-file", "file for the stdout trace exporter", setString(func(c *Config) *string { return &c.Tracing.File })},
+	{"OTEL_SERVICE_NAME", "service-name", "service name reported in traces", setString(func(c *Config) *string { return &c.Tracing.ServiceName })},
+	{"TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "fraction of traces to sample, 0 to 1", setFloat(func(c *Config) *float64 { return &c.Tracing.SampleRatio })},
+	{"AUTH_MODE", "auth-mode", "API authentication: none, apikey or jwt", setString(func(c *Config) *string { return &c.Auth.Mode })},
+	{"AUTH_ALLOW_ANONYMOUS", "auth-allow-anonymous", "allow AUTH_MODE=none, which gives every client full access", setBool(func(c *Config) *bool { return &c.Auth.AllowAnonymous })},
+	{"AUTH_JWKS", "auth-jwks", "JWKS file path or http(s) URL used to verify bearer tokens", setString(func(c *Config) *string { return &c.Auth.JWKS })},
+	{"AUTH_JWKS_REFRESH", "auth-jwks-refresh", "how often to refetch the JWKS", setDuration(func(c *Config) *time.Duration { return &c.Auth.JWKSRefresh })},
+	{"AUTH_ISSUER", "auth-issuer", "required iss claim of bearer tokens", setString(func(c *Config) *string { return &c.Auth.Issuer })},
+	{"AUTH_AUDIENCE", "auth-audience", "required aud claim of bearer tokens", setString(func(c *Config) *string { return &c.Auth.Audience })},
+	{"AUTH_ROLES_CLAIM", "auth-roles-claim", "claim holding the roles, dots select nested claims", setString(func(c *Config) *string { return &c.Auth.RolesClaim })},
+	{"AUTH_TENANT_CLAIM", "auth-tenant-claim", "claim binding a token to a tenant, empty to ignore", setString(func(c *Config) *string { return &c.Auth.TenantClaim })},
+	{"AUTH_VIEWER_ROLES", "auth-viewer-roles", "comma-separated claim values granting the viewer role", setList(func(c *Config) *[]string { return &c.Auth.ViewerRoles })},
+	{"AUTH_UPLOADER_ROLES", "auth-uploader-roles", "comma-separated claim values granting the uploader role", setList(func(c *Config) *[]string { return &c.Auth.UploaderRoles })},
+	{"AUTH_ADMIN_ROLES", "auth-admin-roles", "comma-separated claim values granting the admin role", setList(func(c *Config) *[]string { return &c.Auth.AdminRoles })},
+	{"TENANT_HEADER", "tenant-header", "header selecting the tenant for credentials not bound to one", setString(func(c *Config) *string { return &c.Tenant.Header })},
+	{"TENANT_DEFAULT", "tenant-default", "tenant of requests that do not name one", setString(func(c *Config) *string { return &c.Tenant.Default })},
+	{"TENANT_RLS", "tenant-rls", "enforce tenant isolation with Postgres row-level security", setBool(func(c *Config) *bool { return &c.Tenant.RLS })},
+	{"UPLOAD_RATE_PER_MINUTE", "upload-rate-per-minute", "uploads per minute allowed for each client, 0 for unlimited", setFloat(func(c *Config) *float64 { return &c.Limits.UploadRatePerMinute })},
+	{"UPLOAD_BURST", "upload-burst", "uploads a client may send at once before the rate applies", setInt(func(c *Config) *int { return &c.Limits.UploadBurst })},
+	{"MAX_CONCURRENT_IMPORTS", "max-concurrent-imports", "imports processed at once across all clients, 0 for unlimited", setInt(func(c *Config) *int { return &c.Limits.MaxConcurrentImports })},
+	{"IMPORT_QUEUE_TIMEOUT", "import-queue-timeout", "how long an upload waits for a free import slot before 429", setDuration(func(c *Config) *time.Duration { return &c.Limits.ImportQueueTimeout })},
+	{"MAX_ARCHIVE_SIZE_MB", "max-archive-size-mb", "largest accepted upload archive in MB, 0 for unlimited", setUint(func(c *Config) *uint64 { return &c.Limits.MaxArchiveSizeMB })},
+	{"MAX_ENTRY_SIZE_MB", "max-entry-size-mb", "largest decompressed archive entry in MB, 0 for unlimited", setUint(func(c *Config) *uint64 { return &c.Limits.MaxEntrySizeMB })},
+	{"MAX_EXTRACTED_SIZE_MB", "max-extracted-size-mb", "largest decompressed size of all archive entries in MB, 0 for unlimited", setUint(func(c *Config) *uint64 { return &c.Limits.MaxExtractedSizeMB })},
+	{"MAX_COMPRESSION_RATIO", "max-compression-ratio", "largest decompressed to compressed size ratio of an entry, 0 for unlimited", setFloat(func(c *Config) *float64 { return &c.Limits.MaxCompressionRatio })},
+	{"MAX_ARCHIVE_ENTRIES", "max-archive-entries", "most entries an archive may contain, 0 for unlimited", setInt(func(c *Config) *int { return &c.Limits.MaxArchiveEntries })},
+	{"QUARANTINE_DIR", "quarantine-dir", "directory keeping uploads that failed to import, empty to discard them", setString(func(c *Config) *string { return &c.Quarantine.Dir })},
+	{"QUARANTINE_TTL", "quarantine-ttl", "how long quarantined uploads are kept", setDuration(func(c *Config) *time.Duration { return &c.Quarantine.TTL })},
+	{"STORAGE_BACKEND", "storage-backend", "blob storage for uploads and exports: none, filesystem or s3", setString(func(c *Config) *string { return &c.Storage.Backend })},
+	{"STORAGE_DIR", "storage-dir", "root directory of the filesystem storage backend", setString(func(c *Config) *string { return &c.Storage.Dir })},
+	{"S3_ENDPOINT", "s3-endpoint", "host[:port] of the S3-compatible service", setString(func(c *Config) *string { return &c.Storage.S3Endpoint })},
+	{"S3_REGION", "s3-region", "S3 region", setString(func(c *Config) *string { return &c.Storage.S3Region })},
+	{"S3_BUCKET", "s3-bucket", "S3 bucket, created when missing", setString(func(c *Config) *string { return &c.Storage.S3Bucket })},
+	{"S3_ACCESS_KEY", "s3-access-key", "S3 access key", setString(func(c *Config) *string { return &c.Storage.S3AccessKey })},
+	{"S3_SECRET_KEY", "s3-secret-key", "S3 secret key", setString(func(c *Config) *string { return &c.Storage.S3SecretKey })},
+	{"S3_USE_SSL", "s3-use-ssl", "connect to the S3 endpoint over HTTPS", setBool(func(c *Config) *bool { return &c.Storage.S3UseSSL })},
+	{"STORAGE_LINK_SECRET", "storage-link-secret", "secret signing download links, random per process when empty", setString(func(c *Config) *string { return &c.Storage.LinkSecret })},
+	{"STORAGE_LINK_TTL", "storage-link-ttl", "how long download links stay valid", setDuration(func(c *Config) *time.Duration { return &c.Storage.LinkTTL })},
+	{"EXPORT_LINK_MIN_SIZE_MB", "export-link-min-size-mb", "exports of at least this size in MB are served by a download link", setUint(func(c *Config) *uint64 { return &c.Storage.ExportLinkMinSizeMB })},
+	{"IMPORT_URL_HOSTS", "import-url-hosts", "comma-separated hosts server-side imports may fetch from over http(s), * for any", setList(func(c *Config) *[]string { return &c.Import.URLHosts })},
+	{"IMPORT_FILE_ROOTS", "import-file-roots", "comma-separated directories whose <tenant> subdirectories server-side imports may read file:// URLs from", setList(func(c *Config) *[]string { return &c.Import.FileRoots })},
+	{"IMPORT_FETCH_TIMEOUT", "import-fetch-timeout", "timeout of fetching the source of a server-side import", setDuration(func(c *Config) *time.Duration { return &c.Import.FetchTimeout })},
+	{"IMPORT_WATCH_DIR", "import-watch-dir", "directory whose .zip, .tar and .csv files are imported automatically, empty to disable", setString(func(c *Config) *string { return &c.Import.WatchDir })},
+	{"IMPORT_WATCH_INTERVAL", "import-watch-interval", "how often the watched directory is scanned", setDuration(func(c *Config) *time.Duration { return &c.Import.WatchInterval })},
+	{"IMPORT_WATCH_NOTIFY", "import-watch-notify", "also scan the watched directory on inotify events", setBool(func(c *Config) *bool { return &c.Import.WatchNotify })},
+	{"IMPORT_WATCH_SETTLE", "import-watch-settle", "how long a file must stay unmodified before it is imported", setDuration(func(c *Config) *time.Duration { return &c.Import.WatchSettle })},
+	{"IMPORT_WATCH_TENANT", "import-watch-tenant", "tenant of watched imports, the default tenant when empty", setString(func(c *Config) *string { return &c.Import.WatchTenant })},
+	{"IMPORT_WATCH_MODE", "import-watch-mode", "upload mode of watched imports", setString(func(c *Config) *string { return &c.Import.WatchMode })},
+	{"IMPORT_WATCH_ID_STRATEGY", "import-watch-id-strategy", "id strategy of watched imports", setString(func(c *Config) *string { return &c.Import.WatchIDStrategy })},
+	{"WEBHOOK_TIMEOUT", "webhook-timeout", "timeout of one webhook delivery attempt", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.Timeout })},
+	{"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "attempts before a webhook delivery is marked as failed", setInt(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{"WEBHOOK_RETRY_INITIAL", "webhook-retry-initial", "delay before the first retry of a webhook delivery, doubled after each attempt", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.RetryInitial })},
+	{"WEBHOOK_RETRY_MAX_DELAY", "webhook-retry-max-delay", "maximum delay between webhook delivery attempts", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.RetryMaxDelay })},
+	{"WEBHOOK_POLL_INTERVAL", "webhook-poll-interval", "how often pending webhook deliveries are looked up", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.PollInterval })},
+	{"WEBHOOK_RETENTION", "webhook-retention", "how long finished webhook deliveries are kept in the log, 0 to keep them forever", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.Retention })},
+	{"EVENTS_POLL_INTERVAL", "events-poll-interval", "how often price event streams check the log when no notification arrives", setDuration(func(c *Config) *time.Duration { return &c.Events.PollInterval })},
+	{"EVENTS_HEARTBEAT", "events-heartbeat", "interval of keepalive comments on idle price event streams", setDuration(func(c *Config) *time.Duration { return &c.Events.Heartbeat })},
+	{"EVENTS_RETENTION", "events-retention", "how long price events are kept for resuming streams", setDuration(func(c *Config) *time.Duration { return &c.Events.Retention })},
+}
+
+// Load builds the configuration from defaults, the config file, environment
+// variables and command line flags, each overriding the previous one. It
+// returns the arguments left after the global flags.
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("app", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+
+	var overrides []func(cfg *Config) error
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		f := f
+		flags.Func(f.flag, f.usage, func(value string) error {
+			overrides = append(overrides, func(cfg *Config) error {
+				if err := f.set(cfg, value); err != nil {
+					return fmt.Errorf("invalid -%s: %w", f.flag, err)
+				}
+				return nil
+			})
+			return nil
+		})
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+
+	if *configPath != "" {
+		if err := loadFile(*configPath, cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, f := range fields {
+		value := os.Getenv(f.env)
+		if value == "" {
+			continue
+		}
+		if err := f.set(cfg, value); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", f.env, err)
+		}
+	}
+
+	for _, override := range overrides {
+		if err := override(cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		_, err = toml.Decode(string(data), cfg)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+const redacted = "******"
+
+var dsnPasswordPattern = regexp.MustCompile(`password=('(?:[^'\\]|\\.)*'|\S+)`)
+
+func (c Config) Redacted() Config {
+	if c.DB.Password != "" {
+		c.DB.Password = redacted
+	}
+	c.DB.URL = redactDSN(c.DB.URL)
+	if c.Storage.S3SecretKey != "" {
+		c.Storage.S3SecretKey = redacted
+	}
+	if c.Storage.LinkSecret != "" {
+		c.Storage.LinkSecret = redacted
+	}
+	return c
+}
+
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "password="+redacted)
+}
+
+func setString(get func(*Config) *string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		*get(cfg) = value
+		return nil
+	}
+}
+
+func setList(get func(*Config) *[]string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*get(cfg) = items
+		return nil
+	}
+}
+
+func setDuration(get func(*Config) *time.Duration) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*get(cfg) = d
+		return nil
+	}
+}
+
+func setInt(get func(*Config) *int) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*get(cfg) = n
+		return nil
+	}
+}
+
+func setUint(get func(*Config) *uint64) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*get(cfg) = n
+		return nil
+	}
+}
+
+func setBool(get func(*Config) *bool) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*get(cfg) = b
+		return nil
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func setFloat(get func(*Config) *float64) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*get(cfg) = f
+		return nil
 	}
-	return defaultValue
 }
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"project_sem/internal/models"
+	"project_sem/internal/tenant"
+)
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.DB.URL == "" {
+		if c.DB.Host == "" {
+			errs = append(errs, errors.New("db.host is required (POSTGRES_HOST)"))
+		}
+		if err := validatePort(c.DB.Port); err != nil {
+			errs = append(errs, fmt.Errorf("db.port (POSTGRES_PORT): %w", err))
+		}
+		if c.DB.Database == "" {
+			errs = append(errs, errors.New("db.database is required (POSTGRES_DB)"))
+		}
+		if c.DB.User == "" {
+			errs = append(errs, errors.New("db.user is required (POSTGRES_USER)"))
+		}
+		if c.DB.Password == "" {
+			errs = append(errs, errors.New("db.password is required (POSTGRES_PASSWORD)"))
+		}
+		switch c.DB.SSLMode {
+		case "disable", "require", "verify-ca", "verify-full":
+		default:
+			errs = append(errs, fmt.Errorf("db.sslmode (POSTGRES_SSLMODE): unknown mode %q", c.DB.SSLMode))
+		}
+		if (c.DB.SSLCert == "") != (c.DB.SSLKey == "") {
+			errs = append(errs, errors.New("db.sslcert and db.sslkey must be set together (POSTGRES_SSLCERT, POSTGRES_SSLKEY)"))
+		}
+	}
+	if c.DB.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("db.max_open_conns (DB_MAX_OPEN_CONNS) must not be negative"))
+	}
+	if c.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db.max_idle_conns (DB_MAX_IDLE_CONNS) must not be negative"))
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("db.max_idle_conns (DB_MAX_IDLE_CONNS) must not exceed db.max_open_conns"))
+	}
+	if c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("db.conn_max_lifetime and db.conn_max_idle_time must not be negative"))
+	}
+	if c.DB.RetryInitial <= 0 {
+		errs = append(errs, errors.New("db.retry_initial (DB_RETRY_INITIAL) must be positive"))
+	}
+	if c.DB.RetryMaxDelay < c.DB.RetryInitial {
+		errs = append(errs, errors.New("db.retry_max_delay (DB_RETRY_MAX_DELAY) must not be less than db.retry_initial"))
+	}
+	if c.DB.RetryMaxWait < 0 {
+		errs = append(errs, errors.New("db.retry_max_wait (DB_RETRY_MAX_WAIT) must not be negative"))
+	}
+
+	if err := validatePort(c.Server.Port); err != nil {
+		errs = append(errs, fmt.Errorf("server.port (SERVER_PORT): %w", err))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive"))
+	}
+	if c.Server.UploadTimeout < 0 {
+		errs = append(errs, errors.New("server.upload_timeout (UPLOAD_TIMEOUT) must not be negative"))
+	}
+	if c.Server.ExportTimeout < 0 {
+		errs = append(errs, errors.New("server.export_timeout (EXPORT_TIMEOUT) must not be negative"))
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level (LOG_LEVEL): unknown level %q", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format (LOG_FORMAT): unknown format %q", c.Log.Format))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.ServiceName == "" {
+			errs = append(errs, errors.New("tracing.service_name (OTEL_SERVICE_NAME) is required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter (TRACING_EXPORTER): unknown exporter %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1"))
+	}
+
+	switch c.Auth.Mode {
+	case "none":
+		if !c.Auth.AllowAnonymous {
+			errs = append(errs, errors.New("auth.mode (AUTH_MODE): none gives every client full access, set auth.allow_anonymous (AUTH_ALLOW_ANONYMOUS) to confirm"))
+		}
+	case "apikey":
+	case "jwt":
+		if c.Auth.JWKS == "" {
+			errs = append(errs, errors.New("auth.jwks (AUTH_JWKS) is required in jwt mode"))
+		}
+		if c.Auth.RolesClaim == "" {
+			errs = append(errs, errors.New("auth.roles_claim (AUTH_ROLES_CLAIM) is required in jwt mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.mode (AUTH_MODE): unknown mode %q", c.Auth.Mode))
+	}
+
+	if c.Tenant.Header == "" {
+		errs = append(errs, errors.New("tenant.header (TENANT_HEADER) is required"))
+	}
+	if !tenant.Valid(c.Tenant.Default) {
+		errs = append(errs, fmt.Errorf("tenant.default (TENANT_DEFAULT): invalid tenant %q", c.Tenant.Default))
+	}
+
+	if c.Limits.UploadRatePerMinute < 0 {
+		errs = append(errs, errors.New("limits.upload_rate_per_minute (UPLOAD_RATE_PER_MINUTE) must not be negative"))
+	}
+	if c.Limits.UploadRatePerMinute > 0 && c.Limits.UploadBurst < 1 {
+		errs = append(errs, errors.New("limits.upload_burst (UPLOAD_BURST) must be at least 1"))
+	}
+	if c.Limits.MaxConcurrentImports < 0 {
+		errs = append(errs, errors.New("limits.max_concurrent_imports (MAX_CONCURRENT_IMPORTS) must not be negative"))
+	}
+	if c.Limits.ImportQueueTimeout < 0 {
+		errs = append(errs, errors.New("limits.import_queue_timeout (IMPORT_QUEUE_TIMEOUT) must not be negative"))
+	}
+	if c.Limits.MaxCompressionRatio < 0 {
+		errs = append(errs, errors.New("limits.max_compression_ratio (MAX_COMPRESSION_RATIO) must not be negative"))
+	}
+	if c.Limits.MaxArchiveEntries < 0 {
+		errs = append(errs, errors.New("limits.max_archive_entries (MAX_ARCHIVE_ENTRIES) must not be negative"))
+	}
+
+	if c.Quarantine.Dir != "" && c.Quarantine.TTL <= 0 {
+		errs = append(errs, errors.New("quarantine.ttl (QUARANTINE_TTL) must be positive"))
+	}
+
+	switch c.Storage.Backend {
+	case "none":
+	case "filesystem":
+		if c.Storage.Dir == "" {
+			errs = append(errs, errors.New("storage.dir (STORAGE_DIR) is required for the filesystem backend"))
+		}
+	case "s3":
+		if c.Storage.S3Endpoint == "" {
+			errs = append(errs, errors.New("storage.s3_endpoint (S3_ENDPOINT) is required for the s3 backend"))
+		}
+		if c.Storage.S3Bucket == "" {
+			errs = append(errs, errors.New("storage.s3_bucket (S3_BUCKET) is required for the s3 backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend (STORAGE_BACKEND): unknown backend %q", c.Storage.Backend))
+	}
+	if c.Storage.LinkTTL <= 0 {
+		errs = append(errs, errors.New("storage.link_ttl (STORAGE_LINK_TTL) must be positive"))
+	}
+
+	if c.Import.FetchTimeout < 0 {
+		errs = append(errs, errors.New("import.fetch_timeout (IMPORT_FETCH_TIMEOUT) must not be negative"))
+	}
+	if c.Import.WatchDir != "" {
+		if c.Import.WatchInterval <= 0 {
+			errs = append(errs, errors.New("import.watch_interval (IMPORT_WATCH_INTERVAL) must be positive"))
+		}
+		if c.Import.WatchSettle < 0 {
+			errs = append(errs, errors.New("import.watch_settle (IMPORT_WATCH_SETTLE) must not be negative"))
+		}
+		if c.Import.WatchTenant != "" && !tenant.Valid(c.Import.WatchTenant) {
+			errs = append(errs, fmt.Errorf("import.watch_tenant (IMPORT_WATCH_TENANT): invalid tenant %q", c.Import.WatchTenant))
+		}
+		if _, ok := models.ParseUploadMode(c.Import.WatchMode); !ok {
+			errs = append(errs, fmt.Errorf("import.watch_mode (IMPORT_WATCH_MODE): unknown mode %q", c.Import.WatchMode))
+		}
+		if _, ok := models.ParseIDStrategy(c.Import.WatchIDStrategy); !ok {
+			errs = append(errs, fmt.Errorf("import.watch_id_strategy (IMPORT_WATCH_ID_STRATEGY): unknown strategy %q", c.Import.WatchIDStrategy))
+		}
+	}
+
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks.timeout (WEBHOOK_TIMEOUT) must be positive"))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhooks.max_attempts (WEBHOOK_MAX_ATTEMPTS) must be at least 1"))
+	}
+	if c.Webhooks.RetryInitial <= 0 {
+		errs = append(errs, errors.New("webhooks.retry_initial (WEBHOOK_RETRY_INITIAL) must be positive"))
+	}
+	if c.Webhooks.RetryMaxDelay < c.Webhooks.RetryInitial {
+		errs = append(errs, errors.New("webhooks.retry_max_delay (WEBHOOK_RETRY_MAX_DELAY) must not be less than webhooks.retry_initial"))
+	}
+	if c.Webhooks.PollInterval <= 0 {
+		errs = append(errs, errors.New("webhooks.poll_interval (WEBHOOK_POLL_INTERVAL) must be positive"))
+	}
+	if c.Webhooks.Retention < 0 {
+		errs = append(errs, errors.New("webhooks.retention (WEBHOOK_RETENTION) must not be negative"))
+	}
+
+	if c.Events.PollInterval <= 0 {
+		errs = append(errs, errors.New("events.poll_interval (EVENTS_POLL_INTERVAL) must be positive"))
+	}
+	if c.Events.Heartbeat <= 0 {
+		errs = append(errs, errors.New("events.heartbeat (EVENTS_HEARTBEAT) must be positive"))
+	}
+	if c.Events.Retention <= 0 {
+		errs = append(errs, errors.New("events.retention (EVENTS_RETENTION) must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	if n < 1 || n > 65535 {
+		return fmt.Errorf("port %d out of range 1-65535", n)
+	}
+	return nil
+}
@@ -1,32 +1,133 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 	"project_sem/internal/config"
 )
 
-func Connect(cfg config.DBConfig) (*sql.DB, error) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host,
-		cfg.Port,
-		cfg.User,
-		cfg.Password,
-		cfg.Database,
-	)
-
-	db, err := sql.Open("postgres", connStr)
+// Connect opens the pool and waits for the database to accept connections,
+// retrying as configured.
+func Connect(ctx context.Context, cfg config.DBConfig) (*sql.DB, error) {
+	db, err := Open(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database connection: %w", err)
+		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := WaitForConnection(ctx, db, cfg, nil); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, err
 	}
 
 	return db, nil
 }
+
+// Open configures the connection pool without connecting.
+func Open(cfg config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", DSN(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// WaitForConnection pings the database until it answers. The delay between
+// attempts doubles from cfg.RetryInitial up to cfg.RetryMaxDelay with random
+// jitter, and the wait gives up after cfg.RetryMaxWait unless that is zero.
+// onError, when not nil, receives the error of every failed attempt.
+func WaitForConnection(ctx context.Context, db *sql.DB, cfg config.DBConfig, onError func(error)) error {
+	if cfg.RetryMaxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.RetryMaxWait)
+		defer cancel()
+	}
+
+	delay := cfg.RetryInitial
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			slog.Info("Connected to database", "attempt", attempt)
+			return nil
+		}
+
+		if onError != nil {
+			onError(err)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("database not available after %d attempts: %w", attempt, err)
+		}
+
+		wait := withJitter(delay)
+		slog.Warn("Database not available, retrying",
+			"attempt", attempt, "retry_in", wait.String(), "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("database not available after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+
+		delay = min(delay*2, cfg.RetryMaxDelay)
+	}
+}
+
+// withJitter returns a random duration between d/2 and d so that replicas
+// started together do not retry in lockstep.
+func withJitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
+
+// DSN returns cfg.URL unchanged when it is set, so a full postgres:// URL or
+// key=value string can carry options that DBConfig has no field for.
+// Otherwise the connection string is built from the individual fields.
+func DSN(cfg config.DBConfig) string {
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+
+	params := [][2]string{
+		{"host", cfg.Host},
+		{"port", cfg.Port},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.Database},
+		{"sslmode", cfg.SSLMode},
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		parts = append(parts, p[0]+"="+quoteDSNValue(p[1]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
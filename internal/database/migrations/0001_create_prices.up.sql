@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS prices (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	category VARCHAR(255) NOT NULL,
+	price NUMERIC(10, 2) NOT NULL,
+	create_date DATE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_prices_category ON prices(category);
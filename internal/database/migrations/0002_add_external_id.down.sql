@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_prices_external_id;
+
+ALTER TABLE prices DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,21 @@
+ALTER TABLE prices ADD COLUMN IF NOT EXISTS external_id INTEGER;
+
+UPDATE prices SET external_id = id WHERE external_id IS NULL;
+
+-- The column may have existed before this migration with repeated values,
+-- which would make the index fail with a bare unique violation.
+DO $$
+DECLARE
+	duplicates BIGINT;
+BEGIN
+	SELECT COUNT(*) INTO duplicates FROM (
+		SELECT external_id FROM prices GROUP BY external_id HAVING COUNT(*) > 1
+	) d;
+	IF duplicates > 0 THEN
+		RAISE EXCEPTION 'prices.external_id has % duplicated values, resolve them before migrating', duplicates
+			USING HINT = 'SELECT external_id, array_agg(id) FROM prices GROUP BY external_id HAVING COUNT(*) > 1';
+	END IF;
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prices_external_id ON prices(external_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_prices_natural_key;
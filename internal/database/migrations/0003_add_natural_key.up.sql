@@ -0,0 +1,21 @@
+DROP INDEX IF EXISTS idx_prices_natural_key;
+
+-- Rows repeating the natural key would make the index fail with a bare
+-- unique violation. They are not deleted here: the operator decides which
+-- of them to keep.
+DO $$
+DECLARE
+	duplicates BIGINT;
+BEGIN
+	SELECT COUNT(*) INTO duplicates FROM (
+		SELECT 1 FROM prices GROUP BY name, category, price, create_date HAVING COUNT(*) > 1
+	) d;
+	IF duplicates > 0 THEN
+		RAISE EXCEPTION 'prices has % duplicated (name, category, price, create_date) values, resolve them before migrating', duplicates
+			USING HINT = 'SELECT name, category, price, create_date, array_agg(id) FROM prices GROUP BY name, category, price, create_date HAVING COUNT(*) > 1';
+	END IF;
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prices_natural_key
+	ON prices(name, category, price, create_date);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_prices_batch_id;
+
+ALTER TABLE prices DROP COLUMN IF EXISTS batch_id;
+
+DROP TABLE IF EXISTS upload_batches;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS upload_batches (
+	id BIGSERIAL PRIMARY KEY,
+	subject TEXT NOT NULL,
+	source TEXT NOT NULL,
+	mode VARCHAR(32) NOT NULL,
+	id_strategy VARCHAR(32) NOT NULL,
+	status VARCHAR(32) NOT NULL DEFAULT 'completed',
+	row_count INTEGER NOT NULL DEFAULT 0,
+	inserted_count INTEGER NOT NULL DEFAULT 0,
+	updated_count INTEGER NOT NULL DEFAULT 0,
+	unchanged_count INTEGER NOT NULL DEFAULT 0,
+	duplicates_count INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	rolled_back_at TIMESTAMPTZ
+);
+
+ALTER TABLE prices ADD COLUMN IF NOT EXISTS batch_id BIGINT REFERENCES upload_batches(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_prices_batch_id ON prices(batch_id);
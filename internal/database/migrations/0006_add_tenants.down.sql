@@ -0,0 +1,21 @@
+ALTER TABLE upload_batches NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE upload_batches DISABLE ROW LEVEL SECURITY;
+ALTER TABLE prices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE prices DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON upload_batches;
+DROP POLICY IF EXISTS tenant_isolation ON prices;
+
+DROP INDEX IF EXISTS idx_upload_batches_tenant;
+
+DROP INDEX IF EXISTS idx_prices_tenant_natural_key;
+DROP INDEX IF EXISTS idx_prices_tenant_external_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prices_external_id ON prices(external_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prices_natural_key ON prices(name, category, price, create_date);
+
+ALTER TABLE prices DROP CONSTRAINT IF EXISTS prices_pkey;
+ALTER TABLE prices ADD PRIMARY KEY (id);
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE upload_batches DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE prices DROP COLUMN IF EXISTS tenant_id;
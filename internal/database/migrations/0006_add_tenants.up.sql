@@ -0,0 +1,25 @@
+ALTER TABLE prices ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE upload_batches ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id TEXT;
+
+-- IDs and natural keys only have to be unique within a tenant.
+ALTER TABLE prices DROP CONSTRAINT IF EXISTS prices_pkey;
+ALTER TABLE prices ADD PRIMARY KEY (tenant_id, id);
+
+DROP INDEX IF EXISTS idx_prices_external_id;
+DROP INDEX IF EXISTS idx_prices_natural_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prices_tenant_external_id ON prices(tenant_id, external_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prices_tenant_natural_key
+	ON prices(tenant_id, name, category, price, create_date);
+
+CREATE INDEX IF NOT EXISTS idx_upload_batches_tenant ON upload_batches(tenant_id, id);
+
+-- The policies only take effect once row-level security is enabled on the
+-- tables, which the server does at startup when TENANT_RLS is set.
+CREATE POLICY tenant_isolation ON prices
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+CREATE POLICY tenant_isolation ON upload_batches
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
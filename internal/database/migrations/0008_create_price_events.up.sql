@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS price_events (
+	id BIGSERIAL PRIMARY KEY,
+	tenant_id TEXT NOT NULL,
+	type VARCHAR(32) NOT NULL,
+	batch_id BIGINT,
+	price_id BIGINT,
+	category VARCHAR(255),
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_events_tenant ON price_events(tenant_id, id);
+
+CREATE INDEX IF NOT EXISTS idx_price_events_created_at ON price_events(created_at);
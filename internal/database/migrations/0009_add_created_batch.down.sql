@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_prices_created_batch_id;
+
+ALTER TABLE prices DROP COLUMN IF EXISTS created_batch_id;
@@ -0,0 +1,14 @@
+ALTER TABLE prices ADD COLUMN IF NOT EXISTS created_batch_id BIGINT REFERENCES upload_batches(id) ON DELETE SET NULL;
+
+-- The backfill has to see every tenant's rows. The server forces row-level
+-- security again at startup when TENANT_RLS is set.
+ALTER TABLE prices NO FORCE ROW LEVEL SECURITY;
+
+-- Rows of insert_only and replace batches were inserted by them. Rows of
+-- earlier upsert batches may have existed before, so they stay unknown and a
+-- rollback keeps them.
+UPDATE prices p SET created_batch_id = p.batch_id
+FROM upload_batches b
+WHERE b.id = p.batch_id AND b.mode IN ('insert_only', 'replace') AND p.created_batch_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_prices_created_batch_id ON prices(created_batch_id);
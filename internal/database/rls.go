@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// rlsTables carry the tenant_isolation policy created by migration 0006.
+// The other tables with a tenant_id are read across tenants by design and
+// rely on the WHERE clauses of the repository: api_keys are looked up by
+// hash before the tenant is known and admin keys have none, the delivery
+// worker claims the due webhook_deliveries of every tenant together with
+// their webhooks, and the retention purge deletes old price_events of every
+// tenant.
+var rlsTables = []string{"prices", "upload_batches"}
+
+// SetRowLevelSecurity enables and forces row-level security on the tenant
+// tables, or turns it off, so that the TENANT_RLS setting is authoritative.
+// Forcing makes the policies apply to the table owner the service connects as.
+func SetRowLevelSecurity(ctx context.Context, db *sql.DB, enabled bool) error {
+	for _, table := range rlsTables {
+		// The flags are compared separately, so that a table left enabled
+		// but not forced, or the other way round, is changed as well.
+		var rowSecurity, forced bool
+		err := db.QueryRowContext(ctx,
+			"SELECT relrowsecurity, relforcerowsecurity FROM pg_class WHERE oid = $1::regclass", table).Scan(&rowSecurity, &forced)
+		if err != nil {
+			return fmt.Errorf("failed to read row-level security of %s: %w", table, err)
+		}
+		if rowSecurity == enabled && forced == enabled {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY", table)
+		if !enabled {
+			query = fmt.Sprintf("ALTER TABLE %s DISABLE ROW LEVEL SECURITY, NO FORCE ROW LEVEL SECURITY", table)
+		}
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to change row-level security of %s: %w", table, err)
+		}
+		slog.Info("Row-level security changed", "table", table, "enabled", enabled)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"project_sem/internal/repository"
+)
+
+// Notifier wakes the streams of a tenant when new price events are
+// committed. Any replica may write events, so the wake-ups come from
+// Postgres notifications; streams still poll in case one is lost.
+type Notifier struct {
+	mu   sync.Mutex
+	subs map[string]map[chan struct{}]struct{}
+}
+
+func NewNotifier() *Notifier {
+	return &Notifier{subs: make(map[string]map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel that receives a value after events of the
+// tenant were committed, and a function that ends the subscription.
+func (n *Notifier) Subscribe(tenantID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	if n.subs[tenantID] == nil {
+		n.subs[tenantID] = make(map[chan struct{}]struct{})
+	}
+	n.subs[tenantID][ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subs[tenantID], ch)
+		if len(n.subs[tenantID]) == 0 {
+			delete(n.subs, tenantID)
+		}
+		n.mu.Unlock()
+	}
+}
+
+// Publish wakes the subscribers of the tenant, or of every tenant when
+// tenantID is empty.
+func (n *Notifier) Publish(tenantID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for id, subs := range n.subs {
+		if tenantID != "" && id != tenantID {
+			continue
+		}
+		for ch := range subs {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Listen publishes the notifications of repository.PriceEventsChannel until
+// ctx is done. It keeps its own connection, which pq re-establishes after
+// failures; every subscriber is woken after a reconnect, since
+// notifications sent in between are lost.
+func (n *Notifier) Listen(ctx context.Context, dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			slog.Warn("Price event listener disconnected", "error", err)
+		case pq.ListenerEventReconnected:
+			slog.Info("Price event listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			slog.Warn("Price event listener failed to connect", "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(repository.PriceEventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification := <-listener.NotificationChannel():
+			if notification == nil {
+				n.Publish("")
+				continue
+			}
+			n.Publish(notification.Extra)
+		}
+	}
+}
+
+// RunPurge removes price events older than retention every interval until
+// ctx is done.
+func RunPurge(ctx context.Context, repo *repository.PriceEventRepository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := repo.Purge(ctx, time.Now().Add(-retention))
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to purge price events", "error", err)
+		} else if purged > 0 {
+			slog.Info("Price events purged", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"project_sem/internal/auth"
+	"project_sem/internal/logging"
+	"project_sem/internal/services"
+)
+
+type APIKeysHandler struct {
+	keys *services.APIKeyService
+}
+
+func NewAPIKeysHandler(keys *services.APIKeyService) *APIKeysHandler {
+	return &APIKeysHandler{keys: keys}
+}
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Tenant string   `json:"tenant"`
+	Scopes []string `json:"scopes"`
+}
+
+func (h *APIKeysHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "name is required"})
+		return
+	}
+
+	// Admins bound to a tenant can only issue keys for that tenant.
+	if bound := boundTenant(r); bound != "" {
+		if req.Tenant != "" && req.Tenant != bound {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "cannot issue keys for another tenant"})
+			return
+		}
+		req.Tenant = bound
+	}
+
+	created, err := h.keys.Create(r.Context(), req.Name, req.Tenant, req.Scopes)
+	if errors.Is(err, services.ErrInvalidAPIScope) || errors.Is(err, services.ErrInvalidTenant) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to create api key", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		return
+	}
+
+	logger.Info("API key created",
+		"key_id", created.APIKey.ID, "name", created.APIKey.Name, "key_tenant", created.APIKey.Tenant, "scopes", created.APIKey.Scopes)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (h *APIKeysHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	keys, err := h.keys.List(r.Context(), boundTenant(r))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list api keys", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *APIKeysHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.keys.Revoke(r.Context(), id, boundTenant(r))
+	if err != nil {
+		logger.Error("Failed to revoke api key", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		return
+	}
+	if !revoked {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "api key not found"})
+		return
+	}
+
+	logger.Info("API key revoked", "key_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// boundTenant returns the tenant the caller's credentials are bound to, or
+// an empty string for credentials that may act on every tenant.
+func boundTenant(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		return principal.Tenant
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"project_sem/internal/logging"
+	"project_sem/internal/repository"
+)
+
+const (
+	defaultBatchLimit = 50
+	maxBatchLimit     = 500
+)
+
+type BatchesHandler struct {
+	batches *repository.BatchRepository
+}
+
+func NewBatchesHandler(batches *repository.BatchRepository) *BatchesHandler {
+	return &BatchesHandler{batches: batches}
+}
+
+func (h *BatchesHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := defaultBatchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxBatchLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	batches, err := h.batches.List(r.Context(), limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list upload batches", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batches)
+}
+
+func (h *BatchesHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	batch, err := h.batches.Get(r.Context(), id)
+	if errors.Is(err, repository.ErrBatchNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "batch not found"})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to get upload batch", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batch)
+}
+
+func (h *BatchesHandler) HandleRollback(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.batches.Rollback(r.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrBatchNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "batch not found"})
+		return
+	case errors.Is(err, repository.ErrBatchRolledBack):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "batch already rolled back"})
+		return
+	case errors.Is(err, repository.ErrBatchReplaced):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		logger.Error("Failed to roll back upload batch", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		return
+	}
+
+	logger.Info("Upload batch rolled back", "batch_id", id, "deleted_rows", result.DeletedRows, "kept_rows", result.KeptRows)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// pathID parses the {id} route variable and writes a 400 response when it
+// is not a number.
+func pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package handlers
+
+import "math"
+
+func freeDiskSpace(path string) (uint64, error) {
+	return math.MaxUint64, nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"project_sem/internal/logging"
+	"project_sem/internal/storage"
+)
+
+// BlobStore is the optional blob storage of the prices handler. Uploads are
+// kept under uploads/ and large exports under exports/.
+type BlobStore struct {
+	Storage storage.Storage
+	Links   *storage.Links
+	LinkTTL time.Duration
+	// ExportLinkMinSize is the archive size from which exports are stored
+	// and served by a link instead of in the response body.
+	ExportLinkMinSize int64
+}
+
+// downloadURL prefers a URL presigned by the backend and falls back to a
+// signed link to /api/v0/downloads.
+func (b *BlobStore) downloadURL(r *http.Request, key, filename string) (string, time.Time, error) {
+	expires := time.Now().Add(b.LinkTTL)
+
+	u, err := b.Storage.PresignGet(r.Context(), key, filename, b.LinkTTL)
+	if err == nil {
+		return u, expires, nil
+	}
+	if !errors.Is(err, storage.ErrPresignUnsupported) {
+		return "", time.Time{}, err
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + "/api/v0/downloads/" + b.Links.Sign(key, filename, expires), expires, nil
+}
+
+type DownloadsHandler struct {
+	blobs *BlobStore
+}
+
+func NewDownloadsHandler(blobs *BlobStore) *DownloadsHandler {
+	return &DownloadsHandler{blobs: blobs}
+}
+
+// HandleDownload serves an object named by a signed link. The link is the
+// credential, so the route is not authenticated.
+func (h *DownloadsHandler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+
+	key, filename, err := h.blobs.Links.Verify(mux.Vars(r)["token"], time.Now())
+	if errors.Is(err, storage.ErrLinkExpired) {
+		writeTextError(w, http.StatusGone, "download link expired")
+		return
+	}
+	if err != nil {
+		writeTextError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	object, err := h.blobs.Storage.Stat(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeTextError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to stat stored object", "key", key, "error", err)
+		writeTextError(w, http.StatusInternalServerError, "storage error")
+		return
+	}
+
+	body, err := h.blobs.Storage.Get(r.Context(), key)
+	if err != nil {
+		logger.Error("Failed to open stored object", "key", key, "error", err)
+		writeTextError(w, http.StatusInternalServerError, "storage error")
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if mediaType := mime.TypeByExtension(path.Ext(filename)); mediaType != "" {
+		w.Header().Set("Content-Type", mediaType)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		logger.Warn("Failed to write download", "key", key, "error", err)
+	}
+}
+
+func writeTextError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	w.Write([]byte(message))
+}
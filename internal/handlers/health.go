@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"project_sem/internal/database"
+	"project_sem/internal/logging"
+	"project_sem/internal/version"
+)
+
+const readinessTimeout = 2 * time.Second
+
+var errNotStarted = errors.New("waiting for database")
+
+type HealthHandler struct {
+	db            *sql.DB
+	migrator      *database.Migrator
+	tempDir       string
+	minFreeDiskMB uint64
+	started       atomic.Bool
+	startupErr    atomic.Pointer[string]
+
+	// statuses holds the last status of every check, so that a failing
+	// check is logged when it starts failing rather than on every probe.
+	mu       sync.Mutex
+	statuses map[string]string
+}
+
+func NewHealthHandler(db *sql.DB, migrator *database.Migrator, minFreeDiskMB uint64) *HealthHandler {
+	return &HealthHandler{
+		db:            db,
+		migrator:      migrator,
+		tempDir:       os.TempDir(),
+		minFreeDiskMB: minFreeDiskMB,
+		statuses:      make(map[string]string),
+	}
+}
+
+// MarkStarted is called once the database is reachable and migrated.
+func (h *HealthHandler) MarkStarted() {
+	h.started.Store(true)
+}
+
+// SetStartupError records why the database is not ready yet. /readyz logs
+// it with the failed startup check.
+func (h *HealthHandler) SetStartupError(err error) {
+	msg := err.Error()
+	h.startupErr.Store(&msg)
+}
+
+func (h *HealthHandler) Started() bool {
+	return h.started.Load()
+}
+
+func (h *HealthHandler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func (h *HealthHandler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	// The response is public, so failed checks only report their status and
+	// the reason goes to the log.
+	checks := map[string]string{}
+	for name, err := range map[string]error{
+		"startup":    h.checkStartup(),
+		"database":   h.checkDatabase(ctx),
+		"migrations": h.checkMigrations(ctx),
+		"disk":       h.checkDisk(),
+	} {
+		checks[name] = h.record(r.Context(), name, err)
+	}
+
+	status := "ready"
+	code := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			status = "not ready"
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// record returns the status of a check and logs it when it differs from the
+// previous probe. Every failure is also logged at debug level.
+func (h *HealthHandler) record(ctx context.Context, name string, err error) string {
+	status := "ok"
+	if err != nil {
+		status = "failed"
+		if errors.Is(err, errNotStarted) {
+			status = "waiting"
+		}
+	}
+
+	h.mu.Lock()
+	previous := h.statuses[name]
+	h.statuses[name] = status
+	h.mu.Unlock()
+
+	logger := logging.FromContext(ctx)
+	switch {
+	case status == previous && err != nil:
+		logger.Debug("Readiness check failed", "check", name, "status", status, "error", err)
+	case err != nil:
+		logger.Warn("Readiness check failed", "check", name, "status", status, "error", err)
+	case previous != "" && previous != status:
+		logger.Info("Readiness check recovered", "check", name)
+	}
+	return status
+}
+
+func (h *HealthHandler) HandleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(version.Get())
+}
+
+func (h *HealthHandler) checkStartup() error {
+	if !h.started.Load() {
+		if msg := h.startupErr.Load(); msg != nil {
+			return fmt.Errorf("%w: %s", errNotStarted, *msg)
+		}
+		return errNotStarted
+	}
+	return nil
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) error {
+	if err := h.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) error {
+	current, err := h.migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if expected := database.LatestVersion(); current != expected {
+		return fmt.Errorf("schema version %d, expected %d", current, expected)
+	}
+	return nil
+}
+
+func (h *HealthHandler) checkDisk() error {
+	free, err := freeDiskSpace(h.tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", h.tempDir, err)
+	}
+
+	if freeMB := free / (1 << 20); freeMB < h.minFreeDiskMB {
+		return fmt.Errorf("%d MB free in %s, need at least %d MB", freeMB, h.tempDir, h.minFreeDiskMB)
+	}
+	return nil
+}
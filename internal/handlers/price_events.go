@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"project_sem/internal/events"
+	"project_sem/internal/logging"
+	"project_sem/internal/metrics"
+	"project_sem/internal/models"
+	"project_sem/internal/repository"
+	"project_sem/internal/tenant"
+)
+
+const (
+	// priceEventBatch bounds the events read from the log at once.
+	priceEventBatch = 500
+	// priceEventRetry tells clients how long to wait before reconnecting.
+	priceEventRetry = 3 * time.Second
+)
+
+type PriceEventsOptions struct {
+	PollInterval time.Duration
+	Heartbeat    time.Duration
+}
+
+type PriceEventsHandler struct {
+	events   *repository.PriceEventRepository
+	notifier *events.Notifier
+	opts     PriceEventsOptions
+	shutdown <-chan struct{}
+}
+
+// NewPriceEventsHandler ends open streams when shutdown is closed, so that
+// they do not hold up draining the server.
+func NewPriceEventsHandler(repo *repository.PriceEventRepository, notifier *events.Notifier, opts PriceEventsOptions, shutdown <-chan struct{}) *PriceEventsHandler {
+	return &PriceEventsHandler{events: repo, notifier: notifier, opts: opts, shutdown: shutdown}
+}
+
+// HandleStream sends the tenant's price events as Server-Sent Events. A
+// client resumes after the event in the Last-Event-ID header or the
+// last_event_id parameter, where 0 replays the whole log; without one the
+// stream starts with new events.
+func (h *PriceEventsHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	query := r.URL.Query()
+
+	var lastID int64
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = query.Get("last_event_id")
+	}
+	if resume != "" {
+		id, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil || id < 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid last event id"})
+			return
+		}
+		lastID = id
+	}
+	categories := query["category"]
+
+	wake, unsubscribe := h.notifier.Subscribe(tenant.FromContext(ctx))
+	defer unsubscribe()
+
+	// A client resuming from an event that was purged may have missed
+	// others, so it is told to reload instead.
+	reset := false
+	if lastID > 0 {
+		exists, err := h.events.Has(ctx, lastID)
+		if err != nil {
+			logger.Error("Failed to look up price event", "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+			return
+		}
+		reset = !exists
+	}
+	if resume == "" || reset {
+		id, err := h.events.LastID(ctx)
+		if err != nil {
+			logger.Error("Failed to get last price event", "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+			return
+		}
+		lastID = id
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	metrics.EventStreams.Inc()
+	defer metrics.EventStreams.Dec()
+
+	fmt.Fprintf(w, "retry: %d\n\n", priceEventRetry.Milliseconds())
+	if reset {
+		writeEvent(w, lastID, models.PriceEventReset, []byte(`{}`))
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	poll := time.NewTicker(h.opts.PollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(h.opts.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		batch, err := h.events.After(ctx, lastID, categories, priceEventBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("Failed to read price events", "error", err)
+			}
+			return
+		}
+		for _, event := range batch {
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Error("Failed to encode price event", "error", err)
+				return
+			}
+			writeEvent(w, event.ID, event.Type, data)
+			lastID = event.ID
+		}
+		if len(batch) > 0 {
+			if err := controller.Flush(); err != nil {
+				return
+			}
+			heartbeat.Reset(h.opts.Heartbeat)
+		}
+		if len(batch) == priceEventBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.shutdown:
+			return
+		case <-wake:
+		case <-poll.C:
+		case <-heartbeat.C:
+			io.WriteString(w, ": keepalive\n\n")
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w io.Writer, id int64, event string, data []byte) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+}
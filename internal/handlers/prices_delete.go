@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"project_sem/internal/logging"
+)
+
+func (h *PricesHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.repo.DeletePrice(r.Context(), id)
+	if err != nil {
+		logger.Error("Failed to delete price", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		return
+	}
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "price not found"})
+		return
+	}
+
+	logger.Info("Price deleted", "price_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,14 +1,25 @@
 package handlers
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"project_sem/internal/logging"
+	"project_sem/internal/metrics"
 	"project_sem/internal/repository"
+	"project_sem/internal/storage"
+	"project_sem/internal/tenant"
 )
 
 func (h *PricesHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	queryParams := r.URL.Query()
 
 	var filter repository.PriceFilter
@@ -41,34 +52,156 @@ func (h *PricesHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 		filter.MaxPrice = &maxPrice
 	}
 
-	prices, err := h.repo.GetFilteredPrices(filter)
+	delivery := queryParams.Get("delivery")
+	switch delivery {
+	case "", "inline":
+	case "link":
+		if h.blobs == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("download links require blob storage"))
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid delivery parameter"))
+		return
+	}
+
+	// A stored export is reused while its data version is current, so the
+	// archive is not rebuilt for every request.
+	var exportKey string
+	if h.blobs != nil && delivery != "inline" {
+		key, err := h.exportKey(r.Context(), filter)
+		if err != nil {
+			logger.Error("Failed to get export version", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("database error"))
+			return
+		}
+		exportKey = key
+
+		object, err := h.blobs.Storage.Stat(r.Context(), key)
+		switch {
+		case err == nil && time.Since(object.ModTime) < h.blobs.LinkTTL:
+			metrics.ExportDeliveries.WithLabelValues("cached").Inc()
+			h.writeExportLink(w, r, key, delivery, object.Size)
+			return
+		case err != nil && !errors.Is(err, storage.ErrNotFound):
+			logger.Warn("Failed to look up stored export", "key", key, "error", err)
+		}
+	}
+
+	prices, err := h.repo.GetFilteredPrices(r.Context(), filter)
+	if err != nil && errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		logger.Warn("Export timed out", "error", err)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		w.Write([]byte("request timeout"))
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to get filtered prices: %v", err)
+		logger.Error("Failed to get filtered prices", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("database error"))
 		return
 	}
 
-	csvData, err := h.csvService.Generate(prices)
+	csvData, err := h.csvService.Generate(r.Context(), prices)
+	if err != nil && errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		logger.Warn("Export timed out", "error", err)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		w.Write([]byte("request timeout"))
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to generate CSV: %v", err)
+		logger.Error("Failed to generate CSV", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("failed to generate CSV"))
 		return
 	}
 
-	zipData, err := h.archiveService.CreateZip(csvData, "data.csv")
+	zipData, err := h.archiveService.CreateZip(r.Context(), csvData, "data.csv")
 	if err != nil {
-		log.Printf("Failed to create ZIP: %v", err)
+		logger.Error("Failed to create ZIP", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("failed to create archive"))
 		return
 	}
 
+	logger.Info("Export completed", "rows", len(prices), "archive_bytes", len(zipData))
+	metrics.ExportRows.Observe(float64(len(prices)))
+	metrics.ArchiveSize.WithLabelValues("export").Observe(float64(len(zipData)))
+
+	if exportKey != "" && (delivery == "link" || int64(len(zipData)) >= h.blobs.ExportLinkMinSize) {
+		err := h.blobs.Storage.Put(r.Context(), exportKey, bytes.NewReader(zipData), int64(len(zipData)), "application/zip")
+		if err == nil {
+			metrics.ExportDeliveries.WithLabelValues("link").Inc()
+			h.writeExportLink(w, r, exportKey, delivery, int64(len(zipData)))
+			return
+		}
+		logger.Error("Failed to store export", "key", exportKey, "error", err)
+		if delivery == "link" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("storage error"))
+			return
+		}
+	}
+
+	metrics.ExportDeliveries.WithLabelValues("inline").Inc()
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", "attachment; filename=data.zip")
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(zipData); err != nil {
-		log.Printf("Failed to write response: %v", err)
+		logger.Warn("Failed to write response", "error", err)
+	}
+}
+
+// exportKey names the stored archive of a filtered export of the current
+// tenant at the current data version.
+func (h *PricesHandler) exportKey(ctx context.Context, filter repository.PriceFilter) (string, error) {
+	version, err := h.repo.ExportVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintln(hash, version)
+	for _, value := range []*string{filter.StartDate, filter.EndDate} {
+		if value != nil {
+			fmt.Fprint(hash, *value)
+		}
+		fmt.Fprintln(hash)
+	}
+	for _, value := range []*float64{filter.MinPrice, filter.MaxPrice} {
+		if value != nil {
+			fmt.Fprint(hash, strconv.FormatFloat(*value, 'g', -1, 64))
+		}
+		fmt.Fprintln(hash)
 	}
+
+	return fmt.Sprintf("exports/%s/%x.zip", tenant.FromContext(ctx), hash.Sum(nil)[:16]), nil
+}
+
+// writeExportLink redirects to the stored export, or describes the link in
+// JSON when the client asked for delivery=link.
+func (h *PricesHandler) writeExportLink(w http.ResponseWriter, r *http.Request, key, delivery string, size int64) {
+	link, expires, err := h.blobs.downloadURL(r, key, "data.zip")
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to create download link", "key", key, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("storage error"))
+		return
+	}
+
+	if delivery == "link" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"url":        link,
+			"expires_at": expires.UTC(),
+			"size_bytes": size,
+		})
+		return
+	}
+
+	http.Redirect(w, r, link, http.StatusSeeOther)
 }
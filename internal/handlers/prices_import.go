@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"project_sem/internal/logging"
+	"project_sem/internal/services"
+)
+
+type importRequest struct {
+	URL string `json:"url"`
+}
+
+// HandleImportURL imports an archive the server fetches itself. It takes the
+// same query parameters as HandlePost and a JSON body {"url": "..."}.
+func (h *PricesHandler) HandleImportURL(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	archiveType, opts, ok := parseImportParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req importRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil || req.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "url is required"})
+		return
+	}
+
+	source := req.URL
+	if u, err := url.Parse(req.URL); err == nil {
+		source = u.Redacted()
+	}
+	opts.Source = "url:" + source
+
+	data, filename, err := h.fetcher.Fetch(r.Context(), req.URL)
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+	logger.Info("Import source fetched", "source", source, "size_bytes", len(data))
+
+	response, err := h.importService.Import(r.Context(), data, archiveType, opts)
+	if err != nil {
+		var quarantineID string
+		if h.quarantine != nil && isRejectedContent(err) {
+			quarantineID = h.quarantineUpload(r, data, filename, archiveType, opts, err)
+		}
+		writeImportError(w, r, err, quarantineID)
+		return
+	}
+
+	if h.blobs != nil {
+		h.storeOriginal(r, data, filename, archiveType, response.BatchID)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeFetchError answers with a fixed message per status. The error itself
+// stays in the log, because for URL sources it may name internal hosts,
+// addresses and ports.
+func writeFetchError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context())
+	logger.Warn("Failed to fetch import source", "error", err)
+
+	status, message := http.StatusBadGateway, "source unavailable"
+	switch {
+	case errors.Is(err, services.ErrInvalidSource):
+		status, message = http.StatusBadRequest, "invalid source URL"
+	case errors.Is(err, services.ErrSourceNotAllowed):
+		status, message = http.StatusBadRequest, "source not allowed"
+	case errors.Is(err, services.ErrSourceNotFound):
+		status, message = http.StatusUnprocessableEntity, "source not found"
+	case errors.Is(err, services.ErrArchiveTooLarge):
+		status, message = http.StatusRequestEntityTooLarge, "archive exceeds maximum size"
+	case errors.Is(r.Context().Err(), context.DeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		status, message = http.StatusGatewayTimeout, "source timed out"
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
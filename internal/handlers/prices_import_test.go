@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"project_sem/internal/services"
+)
+
+// TestWriteFetchError checks the status of every fetch error and that the
+// response does not reveal the underlying error.
+func TestWriteFetchError(t *testing.T) {
+	const detail = "dial tcp 10.0.0.5:5432: connect: connection refused"
+
+	for _, tc := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{fmt.Errorf("%w: %s", services.ErrInvalidSource, detail), http.StatusBadRequest, "invalid source URL"},
+		{fmt.Errorf("%w: %s", services.ErrSourceNotAllowed, detail), http.StatusBadRequest, "source not allowed"},
+		{fmt.Errorf("%w: %s", services.ErrSourceNotFound, detail), http.StatusUnprocessableEntity, "source not found"},
+		{fmt.Errorf("%w: %s", services.ErrArchiveTooLarge, detail), http.StatusRequestEntityTooLarge, "archive exceeds maximum size"},
+		{fmt.Errorf("%w: %s", services.ErrSourceUnavailable, detail), http.StatusBadGateway, "source unavailable"},
+		{fmt.Errorf("%w: %s", context.DeadlineExceeded, detail), http.StatusGatewayTimeout, "source timed out"},
+	} {
+		w := httptest.NewRecorder()
+		writeFetchError(w, httptest.NewRequest(http.MethodPost, "/api/v0/prices/import", nil), tc.err)
+
+		var body map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%v: invalid response: %v", tc.err, err)
+		}
+		if w.Code != tc.status || body["error"] != tc.message {
+			t.Errorf("%v: got %d %q, want %d %q", tc.err, w.Code, body["error"], tc.status, tc.message)
+		}
+		if strings.Contains(body["error"], "10.0.0.5") {
+			t.Errorf("%v: response reveals the error", tc.err)
+		}
+	}
+}
@@ -1,116 +1,233 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
 	"net/http"
 
+	"project_sem/internal/auth"
+	"project_sem/internal/logging"
 	"project_sem/internal/models"
+	"project_sem/internal/quarantine"
 	"project_sem/internal/repository"
 	"project_sem/internal/services"
+	"project_sem/internal/storage"
 )
 
+// multipartOverhead is allowed on top of the archive size limit for the
+// multipart boundaries and headers.
+const multipartOverhead = 1 << 20
+
 type PricesHandler struct {
-	archiveService   *services.ArchiveService
-	csvService       *services.CSVService
-	validatorService *services.ValidatorService
-	repo             *repository.PriceRepository
+	importService  *services.ImportService
+	archiveService *services.ArchiveService
+	csvService     *services.CSVService
+	repo           *repository.PriceRepository
+	quarantine     *quarantine.Store
+	blobs          *BlobStore
+	fetcher        *services.SourceFetcher
 }
 
 func NewPricesHandler(
+	importService *services.ImportService,
 	archiveService *services.ArchiveService,
 	csvService *services.CSVService,
-	validatorService *services.ValidatorService,
 	repo *repository.PriceRepository,
+	quarantine *quarantine.Store,
+	blobs *BlobStore,
+	fetcher *services.SourceFetcher,
 ) *PricesHandler {
 	return &PricesHandler{
-		archiveService:   archiveService,
-		csvService:       csvService,
-		validatorService: validatorService,
-		repo:             repo,
+		importService:  importService,
+		archiveService: archiveService,
+		csvService:     csvService,
+		repo:           repo,
+		quarantine:     quarantine,
+		blobs:          blobs,
+		fetcher:        fetcher,
 	}
 }
 
 func (h *PricesHandler) HandlePost(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	w.Header().Set("Content-Type", "application/json")
 
-	archiveType := r.URL.Query().Get("type")
-	if archiveType == "" {
-		archiveType = "zip"
+	archiveType, opts, ok := parseImportParams(w, r)
+	if !ok {
+		return
 	}
 
-	if archiveType != "zip" && archiveType != "tar" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid archive type"})
-		return
+	if max := h.archiveService.MaxArchiveBytes(); max > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, max+multipartOverhead)
 	}
 
 	err := r.ParseMultipartForm(32 << 20)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		logger.Warn("Upload exceeds maximum size", "limit", tooLarge.Limit)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{"error": services.ErrArchiveTooLarge.Error()})
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to parse multipart form: %v", err)
+		logger.Warn("Failed to parse multipart form", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "failed to parse form"})
 		return
 	}
 
-	file, _, err := r.FormFile("file")
+	file, header, err := r.FormFile("file")
 	if err != nil {
-		log.Printf("Failed to get file from form: %v", err)
+		logger.Warn("Failed to get file from form", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "file is required"})
 		return
 	}
 	defer file.Close()
+	opts.Source = "upload:" + header.Filename
 
 	fileData, err := io.ReadAll(file)
 	if err != nil {
-		log.Printf("Failed to read file: %v", err)
+		logger.Warn("Failed to read file", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "failed to read file"})
 		return
 	}
 
-	csvData, err := h.archiveService.Extract(fileData, archiveType)
+	response, err := h.importService.Import(r.Context(), fileData, archiveType, opts)
 	if err != nil {
-		log.Printf("Failed to extract archive: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "corrupted archive"})
+		var quarantineID string
+		if h.quarantine != nil && isRejectedContent(err) {
+			quarantineID = h.quarantineUpload(r, fileData, header.Filename, archiveType, opts, err)
+		}
+		writeImportError(w, r, err, quarantineID)
 		return
 	}
 
-	rawRecords, totalCount, err := h.csvService.Parse(csvData)
-	if err != nil {
-		log.Printf("Failed to parse CSV: %v", err)
+	if h.blobs != nil {
+		h.storeOriginal(r, fileData, header.Filename, archiveType, response.BatchID)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseImportParams reads the type, mode and id_strategy query parameters
+// shared by all import endpoints and writes a 400 response when one is
+// invalid.
+func parseImportParams(w http.ResponseWriter, r *http.Request) (string, models.ImportOptions, bool) {
+	archiveType := r.URL.Query().Get("type")
+	if archiveType == "" {
+		archiveType = "zip"
+	}
+
+	if archiveType != "zip" && archiveType != "tar" {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid CSV format"})
-		return
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid archive type"})
+		return "", models.ImportOptions{}, false
+	}
+
+	mode, ok := models.ParseUploadMode(r.URL.Query().Get("mode"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid mode"})
+		return "", models.ImportOptions{}, false
+	}
+
+	idStrategy, ok := models.ParseIDStrategy(r.URL.Query().Get("id_strategy"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid id strategy"})
+		return "", models.ImportOptions{}, false
 	}
 
-	validationResult, err := h.validatorService.Validate(rawRecords, totalCount)
+	opts := models.ImportOptions{Mode: mode, IDStrategy: idStrategy, Subject: auth.AnonymousSubject}
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		opts.Subject = principal.Subject
+	}
+	return archiveType, opts, true
+}
+
+// storeOriginal keeps the uploaded archive next to its batch. A storage
+// failure is logged and does not fail the upload, which is already committed.
+func (h *PricesHandler) storeOriginal(r *http.Request, data []byte, filename, archiveType string, batchID int64) {
+	logger := logging.FromContext(r.Context())
+
+	key, err := storage.PutUpload(r.Context(), h.blobs.Storage, batchID, filename, archiveType, data)
 	if err != nil {
-		log.Printf("Failed to validate data: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
+		logger.Error("Failed to store uploaded archive", "key", key, "error", err)
 		return
 	}
+	logger.Debug("Uploaded archive stored", "key", key)
+}
 
-	stats, duplicatesCount, err := h.repo.InsertAndGetStats(validationResult.ValidRecords)
+// isRejectedContent reports whether the import failed because of what the
+// client sent, as opposed to a timeout or a database error.
+func isRejectedContent(err error) bool {
+	return errors.Is(err, services.ErrCorruptedArchive) || errors.Is(err, services.ErrInvalidCSV)
+}
+
+func (h *PricesHandler) quarantineUpload(r *http.Request, data []byte, filename, archiveType string, opts models.ImportOptions, importErr error) string {
+	logger := logging.FromContext(r.Context())
+
+	upload, err := h.quarantine.Put(r.Context(), models.QuarantinedUpload{
+		Subject:     opts.Subject,
+		Filename:    filename,
+		ArchiveType: archiveType,
+		Mode:        opts.Mode,
+		IDStrategy:  opts.IDStrategy,
+		Error:       importErr.Error(),
+		Attempts:    1,
+	}, data)
 	if err != nil {
-		log.Printf("Failed to insert data and get statistics: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "database error"})
-		return
+		logger.Error("Failed to quarantine upload", "error", err)
+		return ""
 	}
 
-	response := models.UploadResponse{
-		TotalCount:      validationResult.TotalCount,
-		DuplicatesCount: validationResult.DuplicatesCount + duplicatesCount,
-		TotalItems:      stats.TotalItems,
-		TotalCategories: stats.TotalCategories,
-		TotalPrice:      stats.TotalPrice,
+	logger.Info("Upload quarantined", "quarantine_id", upload.ID, "expires_at", upload.ExpiresAt)
+	return upload.ID
+}
+
+// writeImportError maps an import failure to a response. quarantineID is
+// included when the upload was kept for inspection.
+func writeImportError(w http.ResponseWriter, r *http.Request, err error, quarantineID string) {
+	logger := logging.FromContext(r.Context())
+
+	status := http.StatusBadRequest
+	message := err.Error()
+	switch {
+	case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		logger.Warn("Upload timed out", "error", err)
+		status, message = http.StatusGatewayTimeout, "request timeout"
+	case errors.Is(err, services.ErrArchiveTooLarge),
+		errors.Is(err, services.ErrEntryTooLarge),
+		errors.Is(err, services.ErrExtractedTooLarge):
+		logger.Warn("Archive rejected", "error", err)
+		status = http.StatusRequestEntityTooLarge
+	case services.IsLimitError(err):
+		logger.Warn("Archive rejected", "error", err)
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrCorruptedArchive):
+		logger.Warn("Failed to extract archive", "error", err)
+		message = "corrupted archive"
+	case errors.Is(err, services.ErrInvalidCSV):
+		logger.Warn("Failed to parse CSV", "error", err)
+		message = "invalid CSV format"
+	case errors.Is(err, repository.ErrIDTaken):
+		logger.Warn("Upload conflicts with existing ids", "error", err)
+		status = http.StatusConflict
+		message = err.Error() + "; upload with id_strategy=generate to assign new ids"
+	default:
+		logger.Error("Failed to insert data and get statistics", "error", err)
+		status, message = http.StatusInternalServerError, "database error"
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	body := map[string]string{"error": message}
+	if quarantineID != "" {
+		body["quarantine_id"] = quarantineID
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
 }
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project_sem/internal/repository"
+	"project_sem/internal/services"
+)
+
+func TestWriteImportError(t *testing.T) {
+	for _, tc := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{services.ErrArchiveTooLarge, http.StatusRequestEntityTooLarge, ""},
+		{services.ErrEntryTooLarge, http.StatusRequestEntityTooLarge, ""},
+		{services.ErrExtractedTooLarge, http.StatusRequestEntityTooLarge, ""},
+		{services.ErrCompressionRatio, http.StatusUnprocessableEntity, ""},
+		{services.ErrTooManyEntries, http.StatusUnprocessableEntity, ""},
+		{services.ErrUnsafePath, http.StatusUnprocessableEntity, ""},
+		{services.ErrCorruptedArchive, http.StatusBadRequest, "corrupted archive"},
+		{services.ErrInvalidCSV, http.StatusBadRequest, "invalid CSV format"},
+		{repository.ErrIDTaken, http.StatusConflict, ""},
+		{errors.New("connection reset"), http.StatusInternalServerError, "database error"},
+	} {
+		err := fmt.Errorf("%w: details", tc.err)
+		w := httptest.NewRecorder()
+		writeImportError(w, httptest.NewRequest(http.MethodPost, "/api/v0/prices", nil), err, "q1")
+
+		var body map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%v: invalid response: %v", tc.err, err)
+		}
+		if w.Code != tc.status {
+			t.Errorf("%v: status %d, want %d", tc.err, w.Code, tc.status)
+		}
+		if tc.message != "" && body["error"] != tc.message {
+			t.Errorf("%v: error %q, want %q", tc.err, body["error"], tc.message)
+		}
+		if body["quarantine_id"] != "q1" {
+			t.Errorf("%v: quarantine_id %q, want q1", tc.err, body["quarantine_id"])
+		}
+	}
+}
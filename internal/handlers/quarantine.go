@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"project_sem/internal/auth"
+	"project_sem/internal/logging"
+	"project_sem/internal/models"
+	"project_sem/internal/quarantine"
+	"project_sem/internal/services"
+)
+
+type QuarantineHandler struct {
+	store         *quarantine.Store
+	importService *services.ImportService
+}
+
+func NewQuarantineHandler(store *quarantine.Store, importService *services.ImportService) *QuarantineHandler {
+	return &QuarantineHandler{store: store, importService: importService}
+}
+
+func (h *QuarantineHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	uploads, err := h.store.List(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list quarantined uploads", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "quarantine error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(uploads)
+}
+
+// HandleDownload returns the original bytes as they were uploaded.
+func (h *QuarantineHandler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	upload, data, ok := h.read(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": upload.Filename}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// HandleReprocess imports a quarantined upload again with the options it
+// was sent with. It is removed from quarantine once the import succeeds.
+func (h *QuarantineHandler) HandleReprocess(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+
+	upload, data, ok := h.read(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	// The batch is attributed to the admin running the reprocess, and the
+	// source keeps the subject that uploaded the file.
+	opts := models.ImportOptions{
+		Mode:       upload.Mode,
+		IDStrategy: upload.IDStrategy,
+		Subject:    auth.AnonymousSubject,
+		Source:     "quarantine:" + upload.ID + " uploaded by " + upload.Subject,
+	}
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		opts.Subject = principal.Subject
+	}
+
+	response, err := h.importService.Import(r.Context(), data, upload.ArchiveType, opts)
+	if err != nil {
+		if isRejectedContent(err) {
+			upload.Error = err.Error()
+			upload.Attempts++
+			if err := h.store.Update(upload); err != nil {
+				logger.Error("Failed to update quarantined upload", "quarantine_id", upload.ID, "error", err)
+			}
+		}
+		writeImportError(w, r, err, upload.ID)
+		return
+	}
+
+	if err := h.store.Delete(r.Context(), upload.ID); err != nil {
+		logger.Error("Failed to remove reprocessed upload from quarantine", "quarantine_id", upload.ID, "error", err)
+	}
+	logger.Info("Quarantined upload reprocessed", "quarantine_id", upload.ID, "batch_id", response.BatchID,
+		"subject", opts.Subject, "uploaded_by", upload.Subject)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *QuarantineHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	err := h.store.Delete(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, quarantine.ErrNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "quarantined upload not found"})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete quarantined upload", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "quarantine error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *QuarantineHandler) read(w http.ResponseWriter, r *http.Request) (*models.QuarantinedUpload, []byte, bool) {
+	upload, data, err := h.store.Read(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, quarantine.ErrNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "quarantined upload not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read quarantined upload", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "quarantine error"})
+		return nil, nil, false
+	}
+	return upload, data, true
+}
@@ -0,0 +1,140 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"project_sem/internal/metrics"
+)
+
+var ErrBusy = errors.New("too many concurrent imports")
+
+// Semaphore caps the number of imports running at once across the process.
+// A nil Semaphore never blocks.
+type Semaphore struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// NewSemaphore returns nil when size is not positive. Callers queue for up to
+// wait before Acquire gives up with ErrBusy.
+func NewSemaphore(size int, wait time.Duration) *Semaphore {
+	metrics.ImportSlots.Set(float64(size))
+	if size <= 0 {
+		return nil
+	}
+	return &Semaphore{slots: make(chan struct{}, size), wait: wait}
+}
+
+func (s *Semaphore) Acquire(ctx context.Context) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		timer := time.NewTimer(s.wait)
+		defer timer.Stop()
+
+		select {
+		case s.slots <- struct{}{}:
+		case <-timer.C:
+			metrics.RateLimited.WithLabelValues("concurrency").Inc()
+			return nil, ErrBusy
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	metrics.ImportQueueWait.Observe(time.Since(start).Seconds())
+	metrics.ImportsInFlight.Inc()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			metrics.ImportsInFlight.Dec()
+			<-s.slots
+		})
+	}, nil
+}
+
+// RetryAfter suggests how long a rejected caller should back off.
+func (s *Semaphore) RetryAfter() time.Duration {
+	if s == nil || s.wait < time.Second {
+		return time.Second
+	}
+	return s.wait
+}
+
+// idleClient is how long a client bucket is kept after its last request.
+const idleClient = 10 * time.Minute
+
+// ClientLimiter keeps a token bucket per client. A nil ClientLimiter allows
+// everything.
+type ClientLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*clientBucket
+	lastSweep time.Time
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewClientLimiter returns nil when perMinute is not positive.
+func NewClientLimiter(perMinute float64, burst int) *ClientLimiter {
+	metrics.UploadRateLimit.Set(perMinute)
+	if perMinute <= 0 {
+		return nil
+	}
+	return &ClientLimiter{
+		limit:     rate.Limit(perMinute / 60),
+		burst:     burst,
+		clients:   make(map[string]*clientBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the client's bucket. When the bucket is empty it
+// reports how long until the next token is available.
+func (l *ClientLimiter) Allow(client string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) > idleClient {
+		for key, bucket := range l.clients {
+			if now.Sub(bucket.lastSeen) > idleClient {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.clients[client]
+	if !ok {
+		bucket = &clientBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = bucket
+	}
+	bucket.lastSeen = now
+	reservation := bucket.limiter.ReserveN(now, 1)
+	l.mu.Unlock()
+
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		metrics.RateLimited.WithLabelValues("rate").Inc()
+		return false, delay
+	}
+	return true, 0
+}
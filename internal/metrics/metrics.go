@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "prices"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"route", "method", "status"})
+
+	UploadRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_rows_total",
+		Help:      "Uploaded CSV rows by result: parsed, valid, rejected or duplicate.",
+	}, []string{"result"})
+
+	ArchiveSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "archive_size_bytes",
+		Help:      "Size of uploaded and exported archives.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+	}, []string{"direction"})
+
+	ExportRows = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "export_rows",
+		Help:      "Number of rows written per export.",
+		Buckets:   prometheus.ExponentialBuckets(1, 10, 8),
+	})
+
+	ArchiveRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "archive_rejected_total",
+		Help:      "Uploaded archives rejected by a safety limit.",
+	}, []string{"reason"})
+
+	QuarantinedUploads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quarantined_uploads_total",
+		Help:      "Failed uploads kept in quarantine.",
+	})
+
+	ExportDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_deliveries_total",
+		Help:      "Exports by delivery: inline, link (stored now) or cached (stored earlier).",
+	}, []string{"delivery"})
+
+	SourceFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "source_fetches_total",
+		Help:      "Archives fetched for server-side imports by URL scheme and result.",
+	}, []string{"scheme", "result"})
+
+	WatchedFiles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watched_files_total",
+		Help:      "Files picked up from the watched directory by result: done, failed or retry.",
+	}, []string{"result"})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by result: delivered, retry or failed.",
+	}, []string{"result"})
+
+	EventStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_streams",
+		Help:      "Open Server-Sent Events streams of price changes.",
+	})
+
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected with 429 by reason: rate or concurrency.",
+	}, []string{"reason"})
+
+	ImportsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "imports_in_flight",
+		Help:      "Imports currently holding a concurrency slot.",
+	})
+
+	ImportSlots = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "import_slots",
+		Help:      "Configured maximum of concurrent imports, 0 for unlimited.",
+	})
+
+	ImportQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "import_queue_wait_seconds",
+		Help:      "Time imports waited for a concurrency slot.",
+		Buckets:   []float64{0.001, 0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	})
+
+	UploadRateLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upload_rate_limit_per_minute",
+		Help:      "Configured uploads per minute allowed for each client, 0 for unlimited.",
+	})
+)
+
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
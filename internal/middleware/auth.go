@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"project_sem/internal/auth"
+	"project_sem/internal/logging"
+)
+
+func Authenticate(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			switch {
+			case errors.Is(err, auth.ErrMissingCredentials):
+				writeUnauthorized(w, "authentication required")
+				return
+			case errors.Is(err, auth.ErrInvalidCredentials):
+				logging.FromContext(r.Context()).Debug("Credentials rejected", "error", err)
+				writeUnauthorized(w, "invalid credentials")
+				return
+			case err != nil:
+				logging.FromContext(r.Context()).Error("Authentication failed", "error", err)
+				writeJSONError(w, http.StatusInternalServerError, "authentication failed")
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("subject", principal.Subject))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope must run after Authenticate.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.FromContext(r.Context())
+			if principal == nil {
+				writeUnauthorized(w, "authentication required")
+				return
+			}
+			if !principal.HasScope(scope) {
+				writeJSONError(w, http.StatusForbidden, "missing scope "+scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="prices"`)
+	writeJSONError(w, http.StatusUnauthorized, message)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
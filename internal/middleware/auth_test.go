@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project_sem/internal/auth"
+	"project_sem/internal/models"
+	"project_sem/internal/repository"
+	"project_sem/internal/services"
+	"project_sem/internal/testutil"
+)
+
+// protected serves ok behind the same authentication and scope check as
+// the API routes.
+func protected(authenticator auth.Authenticator, scope string) http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return Authenticate(authenticator)(RequireScope(scope)(ok))
+}
+
+func serve(h http.Handler, header, value string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/v0/prices", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	keys := services.NewAPIKeyService(repository.NewAPIKeyRepository(testutil.DB(t)))
+	ctx := context.Background()
+	tenantID := testutil.TenantID()
+
+	reader, err := keys.Create(ctx, "reader", tenantID, []string{models.ScopePricesRead})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	revoked, err := keys.Create(ctx, "revoked", tenantID, []string{models.ScopePricesRead})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if ok, err := keys.Revoke(ctx, revoked.APIKey.ID, tenantID); err != nil || !ok {
+		t.Fatalf("Revoke: %v, %v", ok, err)
+	}
+
+	read := protected(auth.NewAPIKeyAuthenticator(keys), models.ScopePricesRead)
+	write := protected(auth.NewAPIKeyAuthenticator(keys), models.ScopePricesWrite)
+
+	for _, tc := range []struct {
+		name          string
+		handler       http.Handler
+		header, value string
+		status        int
+	}{
+		{"missing key", read, "", "", http.StatusUnauthorized},
+		{"unknown key", read, auth.APIKeyHeader, "psk_0123456789abcdef", http.StatusUnauthorized},
+		{"not an api key", read, "Authorization", "Bearer something", http.StatusUnauthorized},
+		{"revoked key", read, auth.APIKeyHeader, revoked.Key, http.StatusUnauthorized},
+		{"key in header", read, auth.APIKeyHeader, reader.Key, http.StatusOK},
+		{"key as bearer token", read, "Authorization", "Bearer " + reader.Key, http.StatusOK},
+		{"missing scope", write, auth.APIKeyHeader, reader.Key, http.StatusForbidden},
+	} {
+		w := serve(tc.handler, tc.header, tc.value)
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, w.Code, tc.status, w.Body)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header", tc.name)
+		}
+	}
+}
+
+func TestAnonymousAuthentication(t *testing.T) {
+	for _, scope := range []string{models.ScopePricesRead, models.ScopePricesWrite, models.ScopeAdmin} {
+		if w := serve(protected(auth.Anonymous{}, scope), "", ""); w.Code != http.StatusOK {
+			t.Errorf("anonymous request to a %s route: status %d, want 200", scope, w.Code)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"project_sem/internal/auth"
+	"project_sem/internal/limits"
+	"project_sem/internal/logging"
+)
+
+// RateLimit must run after Authenticate. Authenticated clients are limited by
+// subject, anonymous ones by remote address.
+func RateLimit(limiter *limits.ClientLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, retryAfter := limiter.Allow(clientKey(r)); !ok {
+				logging.FromContext(r.Context()).Info("Upload rate limited", "retry_after", retryAfter.String())
+				writeTooManyRequests(w, retryAfter, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ConcurrencyLimit holds an import slot for the whole request, so it must run
+// before the body is read.
+func ConcurrencyLimit(slots *limits.Semaphore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if slots == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			release, err := slots.Acquire(r.Context())
+			if errors.Is(err, limits.ErrBusy) {
+				logging.FromContext(r.Context()).Info("No free import slot")
+				writeTooManyRequests(w, slots.RetryAfter(), "too many concurrent imports, try again later")
+				return
+			}
+			if err != nil {
+				return
+			}
+			defer release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil && principal.Subject != auth.AnonymousSubject {
+		return "subject:" + principal.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	writeJSONError(w, http.StatusTooManyRequests, message)
+}
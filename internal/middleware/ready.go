@@ -0,0 +1,19 @@
+package middleware
+
+import "net/http"
+
+// RequireReady rejects requests with 503 until ready reports true, so API
+// calls made while the database is still coming up fail fast instead of
+// waiting on the connection pool.
+func RequireReady(ready func() bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !ready() {
+				w.Header().Set("Retry-After", "5")
+				writeJSONError(w, http.StatusServiceUnavailable, "service is starting")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"project_sem/internal/auth"
+	"project_sem/internal/logging"
+	"project_sem/internal/models"
+	"project_sem/internal/tenant"
+)
+
+// Tenant resolves the tenant of the request and must run after
+// Authenticate. Credentials bound to a tenant always act on it. Unbound
+// admins, which includes every client when authentication is disabled, may
+// choose one with the header; everyone else acts on defaultTenant.
+func Tenant(header, defaultTenant string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested := r.Header.Get(header)
+			principal := auth.FromContext(r.Context())
+
+			id := defaultTenant
+			switch {
+			case principal != nil && principal.Tenant != "":
+				id = principal.Tenant
+			case principal != nil && principal.HasScope(models.ScopeAdmin) && requested != "":
+				id = requested
+			}
+			if requested != "" && requested != id {
+				writeJSONError(w, http.StatusForbidden, "access to tenant "+requested+" is not allowed")
+				return
+			}
+
+			if !tenant.Valid(id) {
+				writeJSONError(w, http.StatusBadRequest, "invalid tenant")
+				return
+			}
+
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("tenant.id", id))
+
+			ctx := tenant.WithTenant(r.Context(), id)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("tenant", id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project_sem/internal/auth"
+	"project_sem/internal/models"
+	"project_sem/internal/tenant"
+)
+
+func TestTenant(t *testing.T) {
+	reader := &auth.Principal{Subject: "apikey:reader", Scopes: []string{models.ScopePricesRead}, Tenant: "acme"}
+	unbound := &auth.Principal{Subject: "apikey:unbound", Scopes: []string{models.ScopePricesRead}}
+	admin := &auth.Principal{Subject: "apikey:admin", Scopes: []string{models.ScopeAdmin}}
+	boundAdmin := &auth.Principal{Subject: "apikey:acme-admin", Scopes: []string{models.ScopeAdmin}, Tenant: "acme"}
+
+	for _, tc := range []struct {
+		name      string
+		principal *auth.Principal
+		requested string
+		status    int
+		tenant    string
+	}{
+		{"bound key", reader, "", http.StatusOK, "acme"},
+		{"bound key naming its tenant", reader, "acme", http.StatusOK, "acme"},
+		{"bound key naming another tenant", reader, "globex", http.StatusForbidden, ""},
+		{"bound admin naming another tenant", boundAdmin, "globex", http.StatusForbidden, ""},
+		{"unbound key", unbound, "", http.StatusOK, "default"},
+		{"unbound key naming a tenant", unbound, "globex", http.StatusForbidden, ""},
+		{"admin", admin, "", http.StatusOK, "default"},
+		{"admin naming a tenant", admin, "globex", http.StatusOK, "globex"},
+		{"admin naming an invalid tenant", admin, "../etc", http.StatusBadRequest, ""},
+		{"anonymous naming a tenant", &auth.Principal{Subject: auth.AnonymousSubject, Scopes: []string{models.ScopeAdmin}}, "globex", http.StatusOK, "globex"},
+	} {
+		var got string
+		h := Tenant("X-Tenant-ID", "default")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = tenant.FromContext(r.Context())
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v0/prices", nil)
+		if tc.requested != "" {
+			r.Header.Set("X-Tenant-ID", tc.requested)
+		}
+		r = r.WithContext(auth.WithPrincipal(r.Context(), tc.principal))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, w.Code, tc.status, w.Body)
+		}
+		if got != tc.tenant {
+			t.Errorf("%s: tenant %q, want %q", tc.name, got, tc.tenant)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreatedAPIKey carries the plaintext key, which is only available at
+// creation time.
+type CreatedAPIKey struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
+
+const (
+	ScopePricesRead  = "prices:read"
+	ScopePricesWrite = "prices:write"
+	ScopeAdmin       = "admin"
+)
+
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopePricesRead, ScopePricesWrite, ScopeAdmin:
+		return true
+	default:
+		return false
+	}
+}
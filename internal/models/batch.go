@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	BatchStatusCompleted  = "completed"
+	BatchStatusRolledBack = "rolled_back"
+)
+
+// Batch records one import: who ran it, where the data came from and what
+// it changed. Price rows point to the batch that last wrote them.
+type Batch struct {
+	ID              int64      `json:"id"`
+	Tenant          string     `json:"tenant"`
+	Subject         string     `json:"subject"`
+	Source          string     `json:"source"`
+	Mode            UploadMode `json:"mode"`
+	IDStrategy      IDStrategy `json:"id_strategy"`
+	Status          string     `json:"status"`
+	RowCount        int        `json:"row_count"`
+	InsertedCount   int        `json:"inserted_count"`
+	UpdatedCount    int        `json:"updated_count"`
+	UnchangedCount  int        `json:"unchanged_count"`
+	DuplicatesCount int        `json:"duplicates_count"`
+	CreatedAt       time.Time  `json:"created_at"`
+	RolledBackAt    *time.Time `json:"rolled_back_at,omitempty"`
+}
+
+// RollbackResult counts the rows a rollback deleted and the rows the batch
+// updated, which keep the values it wrote.
+type RollbackResult struct {
+	BatchID     int64 `json:"batch_id"`
+	DeletedRows int64 `json:"deleted_rows"`
+	KeptRows    int64 `json:"kept_rows"`
+}
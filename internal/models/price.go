@@ -10,16 +10,74 @@ type Price struct {
 	CreateDate time.Time `json:"create_date"`
 }
 
+type UploadMode string
+
+const (
+	UploadModeInsertOnly UploadMode = "insert_only"
+	UploadModeUpsert     UploadMode = "upsert"
+	UploadModeReplace    UploadMode = "replace"
+)
+
+func ParseUploadMode(value string) (UploadMode, bool) {
+	switch mode := UploadMode(value); mode {
+	case "":
+		return UploadModeInsertOnly, true
+	case UploadModeInsertOnly, UploadModeUpsert, UploadModeReplace:
+		return mode, true
+	default:
+		return "", false
+	}
+}
+
+type IDStrategy string
+
+const (
+	IDStrategyPreserve IDStrategy = "preserve"
+	IDStrategyGenerate IDStrategy = "generate"
+)
+
+func ParseIDStrategy(value string) (IDStrategy, bool) {
+	switch strategy := IDStrategy(value); strategy {
+	case "":
+		return IDStrategyPreserve, true
+	case IDStrategyPreserve, IDStrategyGenerate:
+		return strategy, true
+	default:
+		return "", false
+	}
+}
+
+type ImportOptions struct {
+	Mode       UploadMode
+	IDStrategy IDStrategy
+	// Subject and Source are recorded on the upload batch.
+	Subject string
+	Source  string
+}
+
 type UploadResponse struct {
 	TotalCount      int     `json:"total_count"`
 	DuplicatesCount int     `json:"duplicates_count"`
+	InsertedCount   int     `json:"inserted_count"`
+	UpdatedCount    int     `json:"updated_count"`
+	UnchangedCount  int     `json:"unchanged_count"`
 	TotalItems      int     `json:"total_items"`
 	TotalCategories int     `json:"total_categories"`
 	TotalPrice      float64 `json:"total_price"`
+	BatchID         int64   `json:"batch_id,omitempty"`
 }
 
 type Statistics struct {
-	TotalItems      int
-	TotalCategories int
-	TotalPrice      float64
+	TotalItems      int     `json:"total_items"`
+	TotalCategories int     `json:"total_categories"`
+	TotalPrice      float64 `json:"total_price"`
+}
+
+type ImportStats struct {
+	Statistics
+	BatchID         int64
+	InsertedCount   int
+	UpdatedCount    int
+	UnchangedCount  int
+	DuplicatesCount int
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	PriceEventCreated        = "created"
+	PriceEventUpdated        = "updated"
+	PriceEventDeleted        = "deleted"
+	PriceEventBatchCompleted = "batch_completed"
+	// PriceEventReset is only sent on streams, when the event a client
+	// resumes from is no longer in the log.
+	PriceEventReset = "reset"
+)
+
+// PriceEvent is one entry of the price change log. Data holds the price for
+// row events and a BatchEventData for batch_completed.
+type PriceEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	BatchID   *int64          `json:"batch_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type BatchEventData struct {
+	BatchID         int64      `json:"batch_id"`
+	Mode            UploadMode `json:"mode"`
+	InsertedCount   int        `json:"inserted_count"`
+	UpdatedCount    int        `json:"updated_count"`
+	UnchangedCount  int        `json:"unchanged_count"`
+	DuplicatesCount int        `json:"duplicates_count"`
+	DeletedCount    int64      `json:"deleted_count"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// QuarantinedUpload describes an upload that failed to import and was kept
+// so that it can be inspected and processed again.
+type QuarantinedUpload struct {
+	ID          string     `json:"id"`
+	Tenant      string     `json:"tenant"`
+	Subject     string     `json:"subject"`
+	Filename    string     `json:"filename"`
+	ArchiveType string     `json:"archive_type"`
+	Mode        UploadMode `json:"mode"`
+	IDStrategy  IDStrategy `json:"id_strategy"`
+	Error       string     `json:"error"`
+	Attempts    int        `json:"attempts"`
+	SizeBytes   int64      `json:"size_bytes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
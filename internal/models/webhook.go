@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventImportCompleted = "import.completed"
+	EventImportFailed    = "import.failed"
+)
+
+func ValidWebhookEvent(event string) bool {
+	switch event {
+	case EventImportCompleted, EventImportFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Tenant    string    `json:"tenant"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreatedWebhook carries the signing secret, which is only returned at
+// creation time.
+type CreatedWebhook struct {
+	Secret  string  `json:"secret"`
+	Webhook Webhook `json:"webhook"`
+}
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookPayload is the body posted to webhook URLs. Response is set for
+// completed imports and Error for failed ones.
+type WebhookPayload struct {
+	Event      string          `json:"event"`
+	Tenant     string          `json:"tenant"`
+	BatchID    int64           `json:"batch_id,omitempty"`
+	Subject    string          `json:"subject"`
+	Source     string          `json:"source"`
+	Mode       UploadMode      `json:"mode"`
+	IDStrategy IDStrategy      `json:"id_strategy"`
+	Response   *UploadResponse `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
@@ -0,0 +1,276 @@
+package quarantine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"project_sem/internal/metrics"
+	"project_sem/internal/models"
+	"project_sem/internal/tenant"
+)
+
+var ErrNotFound = errors.New("quarantined upload not found")
+
+var validID = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}-[0-9a-f]{8}$`)
+
+// Store keeps failed uploads on disk, one directory per tenant. Each upload
+// is a data file plus a JSON metadata file; the metadata is written last, so
+// an upload without it is incomplete and ignored.
+type Store struct {
+	dir string
+	ttl time.Duration
+}
+
+func NewStore(dir string, ttl time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	return &Store{dir: dir, ttl: ttl}, nil
+}
+
+// Put stores data under a new ID in the tenant of ctx.
+func (s *Store) Put(ctx context.Context, upload models.QuarantinedUpload, data []byte) (*models.QuarantinedUpload, error) {
+	id, err := newID(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	upload.ID = id
+	upload.Tenant = tenant.FromContext(ctx)
+	upload.SizeBytes = int64(len(data))
+	upload.CreatedAt = time.Now().UTC()
+	upload.ExpiresAt = upload.CreatedAt.Add(s.ttl)
+
+	dir := filepath.Join(s.dir, upload.Tenant)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	if err := writeFile(s.dataPath(upload.Tenant, id), data); err != nil {
+		return nil, err
+	}
+	if err := s.writeMeta(&upload); err != nil {
+		os.Remove(s.dataPath(upload.Tenant, id))
+		return nil, err
+	}
+
+	metrics.QuarantinedUploads.Inc()
+	return &upload, nil
+}
+
+// List returns the unexpired uploads of the tenant in ctx, newest first.
+func (s *Store) List(ctx context.Context) ([]models.QuarantinedUpload, error) {
+	tenantID := tenant.FromContext(ctx)
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, tenantID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []models.QuarantinedUpload{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine directory: %w", err)
+	}
+
+	now := time.Now()
+	uploads := []models.QuarantinedUpload{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !validID.MatchString(id) {
+			continue
+		}
+		upload, err := s.readMeta(tenantID, id)
+		if err != nil {
+			return nil, err
+		}
+		if upload.ExpiresAt.After(now) {
+			uploads = append(uploads, *upload)
+		}
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].CreatedAt.After(uploads[j].CreatedAt)
+	})
+	return uploads, nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*models.QuarantinedUpload, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrNotFound
+	}
+
+	upload, err := s.readMeta(tenant.FromContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if !upload.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return upload, nil
+}
+
+// Read returns the metadata and the original bytes of an upload.
+func (s *Store) Read(ctx context.Context, id string) (*models.QuarantinedUpload, []byte, error) {
+	upload, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(s.dataPath(upload.Tenant, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read quarantined upload: %w", err)
+	}
+	return upload, data, nil
+}
+
+// Update rewrites the metadata of an existing upload, e.g. after another
+// failed attempt to process it.
+func (s *Store) Update(upload *models.QuarantinedUpload) error {
+	return s.writeMeta(upload)
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if !validID.MatchString(id) {
+		return ErrNotFound
+	}
+	return s.remove(tenant.FromContext(ctx), id)
+}
+
+// Purge removes the uploads of every tenant whose TTL has passed.
+func (s *Store) Purge(now time.Time) (int, error) {
+	tenants, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read quarantine directory: %w", err)
+	}
+
+	var purged int
+	for _, dir := range tenants {
+		if !dir.IsDir() || !tenant.Valid(dir.Name()) {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, dir.Name()))
+		if err != nil {
+			return purged, fmt.Errorf("failed to read quarantine directory: %w", err)
+		}
+		for _, entry := range entries {
+			id, ok := strings.CutSuffix(entry.Name(), ".json")
+			if !ok || !validID.MatchString(id) {
+				continue
+			}
+			upload, err := s.readMeta(dir.Name(), id)
+			if err != nil {
+				slog.Warn("Skipping unreadable quarantined upload", "tenant", dir.Name(), "id", id, "error", err)
+				continue
+			}
+			if upload.ExpiresAt.After(now) {
+				continue
+			}
+			if err := s.remove(dir.Name(), id); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// RunPurge calls Purge every interval until ctx is done.
+func (s *Store) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purged, err := s.Purge(now)
+			if err != nil {
+				slog.Error("Failed to purge quarantined uploads", "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("Purged expired quarantined uploads", "count", purged)
+			}
+		}
+	}
+}
+
+func (s *Store) remove(tenantID, id string) error {
+	err := os.Remove(s.metaPath(tenantID, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete quarantined upload: %w", err)
+	}
+	if err := os.Remove(s.dataPath(tenantID, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete quarantined upload: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) readMeta(tenantID, id string) (*models.QuarantinedUpload, error) {
+	content, err := os.ReadFile(s.metaPath(tenantID, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine metadata: %w", err)
+	}
+
+	var upload models.QuarantinedUpload
+	if err := json.Unmarshal(content, &upload); err != nil {
+		return nil, fmt.Errorf("failed to parse quarantine metadata %s: %w", id, err)
+	}
+	return &upload, nil
+}
+
+func (s *Store) writeMeta(upload *models.QuarantinedUpload) error {
+	content, err := json.MarshalIndent(upload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantine metadata: %w", err)
+	}
+	return writeFile(s.metaPath(upload.Tenant, upload.ID), content)
+}
+
+func (s *Store) dataPath(tenantID, id string) string {
+	return filepath.Join(s.dir, tenantID, id+".data")
+}
+
+func (s *Store) metaPath(tenantID, id string) string {
+	return filepath.Join(s.dir, tenantID, id+".json")
+}
+
+// writeFile writes through a temporary file so that readers never see a
+// partial file.
+func writeFile(path string, content []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o640); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func newID(now time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate quarantine id: %w", err)
+	}
+	return now.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix), nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"project_sem/internal/models"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = "id, name, tenant_id, prefix, scopes, created_at, last_used_at, revoked_at"
+
+// Create binds the key to tenantID unless it is empty.
+func (r *APIKeyRepository) Create(ctx context.Context, name, tenantID, prefix, hash string, scopes []string) (*models.APIKey, error) {
+	query := `
+		INSERT INTO api_keys (name, tenant_id, prefix, key_hash, scopes)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, name, tenantID, prefix, hash, pq.Array(scopes)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+	return key, nil
+}
+
+// List returns the keys bound to tenantID, or all keys when it is empty.
+func (r *APIKeyRepository) List(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE $1 = '' OR tenant_id = $1 ORDER BY id", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Revoke reports false when there is no active key with the given id bound
+// to tenantID; an empty tenantID matches any key.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id int64, tenantID string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL AND ($2 = '' OR tenant_id = $2)`, id, tenantID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// Use looks up an active key by its hash and records the time it was used.
+// The time is written at most once a minute, so that a busy key does not
+// rewrite its row on every request. It returns nil without an error when no
+// such key exists.
+func (r *APIKeyRepository) Use(ctx context.Context, hash string) (*models.APIKey, error) {
+	query := `
+		WITH key AS (
+			SELECT ` + apiKeyColumns + ` FROM api_keys
+			WHERE key_hash = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE api_keys SET last_used_at = now()
+			FROM key
+			WHERE api_keys.id = key.id
+				AND (key.last_used_at IS NULL OR key.last_used_at < now() - interval '1 minute')
+		)
+		SELECT ` + apiKeyColumns + ` FROM key`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up api key: %w", err)
+	}
+	return key, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var tenantID sql.NullString
+	var lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Name, &tenantID, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Tenant = tenantID.String
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"project_sem/internal/models"
+	"project_sem/internal/testutil"
+)
+
+// TestAPIKeyUseThrottlesLastUsed checks that last_used_at is written on the
+// first use and then at most once a minute.
+func TestAPIKeyUseThrottlesLastUsed(t *testing.T) {
+	db := testutil.DB(t)
+	repo := NewAPIKeyRepository(db)
+	ctx := context.Background()
+
+	hash := testutil.TenantID()
+	created, err := repo.Create(ctx, "throttled", testutil.TenantID(), "psk_test", hash, []string{models.ScopePricesRead})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	lastUsed := func() time.Time {
+		t.Helper()
+		var at *time.Time
+		if err := db.QueryRow("SELECT last_used_at FROM api_keys WHERE id = $1", created.ID).Scan(&at); err != nil {
+			t.Fatal(err)
+		}
+		if at == nil {
+			t.Fatal("last_used_at is not set")
+		}
+		return *at
+	}
+
+	if _, err := repo.Use(ctx, hash); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	first := lastUsed()
+
+	if _, err := repo.Use(ctx, hash); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if second := lastUsed(); !second.Equal(first) {
+		t.Errorf("last_used_at rewritten within a minute: %v, then %v", first, second)
+	}
+
+	if _, err := db.Exec("UPDATE api_keys SET last_used_at = now() - interval '2 minutes' WHERE id = $1", created.ID); err != nil {
+		t.Fatal(err)
+	}
+	stale := lastUsed()
+	if _, err := repo.Use(ctx, hash); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if third := lastUsed(); !third.After(stale) {
+		t.Errorf("last_used_at not updated after a minute: %v, then %v", stale, third)
+	}
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"project_sem/internal/models"
+	"project_sem/internal/tracing"
+)
+
+var (
+	ErrBatchNotFound   = errors.New("batch not found")
+	ErrBatchRolledBack = errors.New("batch already rolled back")
+	ErrBatchReplaced   = errors.New("replace batches cannot be rolled back")
+)
+
+type BatchRepository struct {
+	db *sql.DB
+}
+
+func NewBatchRepository(db *sql.DB) *BatchRepository {
+	return &BatchRepository{db: db}
+}
+
+const batchColumns = `id, tenant_id, subject, source, mode, id_strategy, status, row_count,
+	inserted_count, updated_count, unchanged_count, duplicates_count, created_at, rolled_back_at`
+
+func (r *BatchRepository) List(ctx context.Context, limit int) ([]models.Batch, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+batchColumns+" FROM upload_batches WHERE tenant_id = $1 ORDER BY id DESC LIMIT $2", tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upload batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []models.Batch{}
+	for rows.Next() {
+		batch, err := scanBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan upload batch: %w", err)
+		}
+		batches = append(batches, *batch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating upload batches: %w", err)
+	}
+
+	return batches, nil
+}
+
+func (r *BatchRepository) Get(ctx context.Context, id int64) (*models.Batch, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	batch, err := scanBatch(tx.QueryRowContext(ctx,
+		"SELECT "+batchColumns+" FROM upload_batches WHERE tenant_id = $1 AND id = $2", tenantID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload batch: %w", err)
+	}
+	return batch, nil
+}
+
+func (r *BatchRepository) Rollback(ctx context.Context, id int64) (*models.RollbackResult, error) {
+	ctx, span := startSpan(ctx, "BatchRepository.Rollback", attribute.Int64("batch.id", id))
+
+	result, err := r.rollback(ctx, id)
+	tracing.End(span, err)
+
+	return result, err
+}
+
+// rollback deletes the rows that the batch inserted, unless a later batch
+// has updated them since. Rows that existed before the batch are kept with
+// the values it wrote, and replace batches are refused, because the rows
+// they deleted cannot be brought back.
+func (r *BatchRepository) rollback(ctx context.Context, id int64) (*models.RollbackResult, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var mode models.UploadMode
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, mode FROM upload_batches WHERE tenant_id = $1 AND id = $2 FOR UPDATE", tenantID, id).Scan(&status, &mode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock upload batch: %w", err)
+	}
+	if status == models.BatchStatusRolledBack {
+		return nil, ErrBatchRolledBack
+	}
+	if mode == models.UploadModeReplace {
+		return nil, ErrBatchReplaced
+	}
+
+	deletedRows, err := deletePrices(ctx, tx, tenantID, id, " AND created_batch_id = $3 AND batch_id = $3", id)
+	if err != nil {
+		return nil, err
+	}
+
+	var keptRows int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM prices WHERE tenant_id = $1 AND batch_id = $2", tenantID, id).Scan(&keptRows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count kept prices: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE upload_batches SET status = $2, rolled_back_at = now() WHERE id = $1",
+		id, models.BatchStatusRolledBack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update upload batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &models.RollbackResult{BatchID: id, DeletedRows: deletedRows, KeptRows: keptRows}, nil
+}
+
+func scanBatch(row rowScanner) (*models.Batch, error) {
+	var batch models.Batch
+	var rolledBackAt sql.NullTime
+
+	err := row.Scan(&batch.ID, &batch.Tenant, &batch.Subject, &batch.Source, &batch.Mode, &batch.IDStrategy, &batch.Status,
+		&batch.RowCount, &batch.InsertedCount, &batch.UpdatedCount, &batch.UnchangedCount, &batch.DuplicatesCount,
+		&batch.CreatedAt, &rolledBackAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if rolledBackAt.Valid {
+		batch.RolledBackAt = &rolledBackAt.Time
+	}
+	return &batch, nil
+}
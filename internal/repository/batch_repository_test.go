@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"project_sem/internal/models"
+	"project_sem/internal/tenant"
+	"project_sem/internal/testutil"
+)
+
+// TestRollbackKeepsExistingRows checks that rolling back an upsert only
+// deletes the rows it inserted and that replace batches are refused.
+func TestRollbackKeepsExistingRows(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := testutil.Tenant(t)
+	prices := NewPriceRepository(db)
+	batches := NewBatchRepository(db)
+
+	preserve := models.ImportOptions{Mode: models.UploadModeInsertOnly, IDStrategy: models.IDStrategyPreserve}
+	if _, err := prices.SaveAndGetStats(ctx, testPrices(2), preserve); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// The upsert corrects the price of the first row and adds a third one.
+	rows := testPrices(3)
+	rows[0].Price = 100
+	upsert := models.ImportOptions{Mode: models.UploadModeUpsert, IDStrategy: models.IDStrategyPreserve}
+	stats, err := prices.SaveAndGetStats(ctx, rows, upsert)
+	if err != nil {
+		t.Fatalf("upsert failed: %v", err)
+	}
+	if stats.InsertedCount != 1 || stats.UpdatedCount != 1 || stats.UnchangedCount != 1 {
+		t.Fatalf("upsert returned %+v", stats)
+	}
+
+	result, err := batches.Rollback(ctx, stats.BatchID)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if result.DeletedRows != 1 || result.KeptRows != 1 {
+		t.Errorf("Rollback returned %+v, want 1 deleted and 1 kept row", result)
+	}
+	if count := countPrices(t, db, ctx); count != 2 {
+		t.Errorf("table has %d rows after the rollback, want 2", count)
+	}
+	var price float64
+	if err := db.QueryRow("SELECT price FROM prices WHERE tenant_id = $1 AND id = 1", tenant.FromContext(ctx)).Scan(&price); err != nil {
+		t.Fatalf("updated row is gone: %v", err)
+	}
+	if price != 100 {
+		t.Errorf("updated row has price %v, want the upserted 100", price)
+	}
+
+	if _, err := batches.Rollback(ctx, stats.BatchID); !errors.Is(err, ErrBatchRolledBack) {
+		t.Errorf("second Rollback returned %v, want ErrBatchRolledBack", err)
+	}
+
+	replace := models.ImportOptions{Mode: models.UploadModeReplace, IDStrategy: models.IDStrategyPreserve}
+	stats, err = prices.SaveAndGetStats(ctx, testPrices(1), replace)
+	if err != nil {
+		t.Fatalf("replace failed: %v", err)
+	}
+	if _, err := batches.Rollback(ctx, stats.BatchID); !errors.Is(err, ErrBatchReplaced) {
+		t.Errorf("Rollback of a replace batch returned %v, want ErrBatchReplaced", err)
+	}
+	if count := countPrices(t, db, ctx); count != 1 {
+		t.Errorf("table has %d rows after the refused rollback, want 1", count)
+	}
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"project_sem/internal/models"
+)
+
+// PriceEventRepository reads the price change log that PriceRepository and
+// BatchRepository write along with the changes. The log is not under
+// row-level security because it is purged across tenants; queries filter
+// on tenant_id themselves.
+type PriceEventRepository struct {
+	db *sql.DB
+}
+
+func NewPriceEventRepository(db *sql.DB) *PriceEventRepository {
+	return &PriceEventRepository{db: db}
+}
+
+// LastID returns the id of the newest event of the tenant, zero if none.
+func (r *PriceEventRepository) LastID(ctx context.Context) (int64, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(id), 0) FROM price_events WHERE tenant_id = $1", tenantID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last price event: %w", err)
+	}
+	return id, nil
+}
+
+// Has reports whether the event is still in the tenant's log.
+func (r *PriceEventRepository) Has(ctx context.Context, id int64) (bool, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM price_events WHERE tenant_id = $1 AND id = $2)", tenantID, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to get price event: %w", err)
+	}
+	return exists, nil
+}
+
+// After returns up to limit events of the tenant newer than afterID in
+// order. With categories, row events of other categories are left out;
+// batch_completed events are always returned.
+func (r *PriceEventRepository) After(ctx context.Context, afterID int64, categories []string, limit int) ([]models.PriceEvent, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, type, batch_id, data, created_at FROM price_events
+		WHERE tenant_id = $1 AND id > $2
+		  AND (COALESCE(cardinality($3::text[]), 0) = 0 OR category IS NULL OR category = ANY($3::text[]))
+		ORDER BY id
+		LIMIT $4`,
+		tenantID, afterID, pq.Array(categories), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.PriceEvent{}
+	for rows.Next() {
+		var event models.PriceEvent
+		var batchID sql.NullInt64
+		var data []byte
+		if err := rows.Scan(&event.ID, &event.Type, &batchID, &data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan price event: %w", err)
+		}
+		if batchID.Valid {
+			event.BatchID = &batchID.Int64
+		}
+		event.Data = data
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating price events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Purge removes the events of every tenant created before the given time.
+func (r *PriceEventRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM price_events WHERE created_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge price events: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge price events: %w", err)
+	}
+	return purged, nil
+}
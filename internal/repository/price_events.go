@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"project_sem/internal/models"
+)
+
+// PriceEventsChannel is the Postgres notification channel announcing new
+// price events. The payload is the tenant.
+const PriceEventsChannel = "price_events"
+
+// priceEventData renders a prices row as the data of a row event.
+const priceEventData = `jsonb_build_object('id', id, 'name', name, 'category', category,
+	'price', price, 'create_date', create_date)`
+
+// lockPriceEvents serializes the event writers of a tenant until the
+// transaction ends. Event ids come from a sequence, so without it a
+// transaction committing later could publish ids below ones a stream has
+// already passed. It also announces the events, which Postgres delivers on
+// commit.
+func lockPriceEvents(ctx context.Context, tx *sql.Tx, tenantID string) error {
+	if _, err := tx.ExecContext(ctx,
+		"SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))", PriceEventsChannel, tenantID); err != nil {
+		return fmt.Errorf("failed to lock price events: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", PriceEventsChannel, tenantID); err != nil {
+		return fmt.Errorf("failed to notify price events: %w", err)
+	}
+	return nil
+}
+
+// deletePrices deletes the prices of the tenant matching condition, whose
+// placeholders start at $3, and logs a deleted event for each of them.
+// causeBatch is the batch responsible for the deletion, zero for none.
+func deletePrices(ctx context.Context, tx *sql.Tx, tenantID string, causeBatch int64, condition string, args ...any) (int64, error) {
+	if err := lockPriceEvents(ctx, tx, tenantID); err != nil {
+		return 0, err
+	}
+
+	query := `
+		WITH deleted AS (
+			DELETE FROM prices WHERE tenant_id = $1` + condition + `
+			RETURNING id, name, category, price, create_date
+		)
+		INSERT INTO price_events (tenant_id, type, batch_id, price_id, category, data)
+		SELECT $1::text, 'deleted', NULLIF($2::bigint, 0), id, category, ` + priceEventData + `
+		FROM deleted ORDER BY id`
+
+	result, err := tx.ExecContext(ctx, query, append([]any{tenantID, causeBatch}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete prices: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deleted rows: %w", err)
+	}
+	return deleted, nil
+}
+
+// recordBatchEvents logs a created or updated event for every row the batch
+// wrote, followed by a batch_completed event.
+func recordBatchEvents(ctx context.Context, tx *sql.Tx, tenantID string, updatedIDs []int64, data models.BatchEventData) error {
+	if err := lockPriceEvents(ctx, tx, tenantID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO price_events (tenant_id, type, batch_id, price_id, category, data)
+		SELECT $1::text, CASE WHEN id = ANY($3::bigint[]) THEN 'updated' ELSE 'created' END, $2::bigint, id, category, ` + priceEventData + `
+		FROM prices WHERE tenant_id = $1 AND batch_id = $2
+		ORDER BY id`
+	if _, err := tx.ExecContext(ctx, query, tenantID, data.BatchID, pq.Array(updatedIDs)); err != nil {
+		return fmt.Errorf("failed to record price events: %w", err)
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch event: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO price_events (tenant_id, type, batch_id, data)
+		VALUES ($1, $2, $3, $4::jsonb)`,
+		tenantID, models.PriceEventBatchCompleted, data.BatchID, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to record batch event: %w", err)
+	}
+	return nil
+}
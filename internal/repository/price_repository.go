@@ -1,13 +1,24 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"project_sem/internal/logging"
 	"project_sem/internal/models"
+	"project_sem/internal/tracing"
 )
 
+// ErrIDTaken is returned when a preserved id of the file already belongs to
+// a record with another external id, typically one whose id was generated.
+var ErrIDTaken = errors.New("id is taken by another record")
+
 type PriceRepository struct {
 	db *sql.DB
 }
@@ -16,42 +27,26 @@ func NewPriceRepository(db *sql.DB) *PriceRepository {
 	return &PriceRepository{db: db}
 }
 
-func (r *PriceRepository) CheckExistingIDs(ids []int) (map[int]bool, error) {
-	if len(ids) == 0 {
-		return make(map[int]bool), nil
-	}
-
-	query := "SELECT id FROM prices WHERE id = ANY($1)"
-	rows, err := r.db.Query(query, pq.Array(ids))
-	if err != nil {
-		return nil, fmt.Errorf("failed to check existing IDs: %w", err)
-	}
-	defer rows.Close()
-
-	existingMap := make(map[int]bool)
-	for rows.Next() {
-		var id int
-		if err := rows.Scan(&id); err != nil {
-			return nil, fmt.Errorf("failed to scan existing ID: %w", err)
-		}
-		existingMap[id] = true
-	}
+func (r *PriceRepository) SaveAndGetStats(ctx context.Context, prices []models.Price, opts models.ImportOptions) (*models.ImportStats, error) {
+	ctx, span := startSpan(ctx, "PriceRepository.SaveAndGetStats",
+		attribute.Int("prices.rows", len(prices)),
+		attribute.String("import.mode", string(opts.Mode)),
+	)
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating existing IDs: %w", err)
-	}
+	stats, err := r.saveAndGetStats(ctx, prices, opts)
+	tracing.End(span, err)
 
-	return existingMap, nil
+	return stats, err
 }
 
-func (r *PriceRepository) InsertAndGetStats(prices []models.Price) (*models.Statistics, int, error) {
-	if len(prices) == 0 {
-		return &models.Statistics{}, 0, nil
+func (r *PriceRepository) saveAndGetStats(ctx context.Context, prices []models.Price, opts models.ImportOptions) (*models.ImportStats, error) {
+	if len(prices) == 0 && opts.Mode != models.UploadModeReplace {
+		return &models.ImportStats{}, nil
 	}
 
-	tx, err := r.db.Begin()
+	tx, tenantID, err := beginTx(ctx, r.db, nil)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
 
 	defer func() {
@@ -61,32 +56,53 @@ func (r *PriceRepository) InsertAndGetStats(prices []models.Price) (*models.Stat
 		}
 	}()
 
-	duplicatesCount := 0
-	insertedCount := 0
+	batchID, err := createBatch(ctx, tx, tenantID, len(prices), opts)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-	for _, price := range prices {
-		var count int
-		checkQuery := `SELECT COUNT(*) FROM prices
-		               WHERE name = $1 AND category = $2 AND price = $3 AND create_date = $4`
-		err = tx.QueryRow(checkQuery, price.Name, price.Category, price.Price, price.CreateDate).Scan(&count)
+	var deleted int64
+	if opts.Mode == models.UploadModeReplace {
+		deleted, err = deletePrices(ctx, tx, tenantID, batchID, "")
 		if err != nil {
 			tx.Rollback()
-			return nil, 0, fmt.Errorf("failed to check duplicate: %w", err)
+			return nil, err
 		}
+		logging.FromContext(ctx).Info("Tenant prices cleared for replace upload", "deleted_rows", deleted)
+	}
 
-		if count > 0 {
-			duplicatesCount++
-			continue
-		}
+	stats, updatedIDs, err := saveRows(ctx, tx, prices, tenantID, batchID, opts)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	stats.BatchID = batchID
 
-		insertQuery := `INSERT INTO prices (name, category, price, create_date)
-		                VALUES ($1, $2, $3, $4)`
-		_, err = tx.Exec(insertQuery, price.Name, price.Category, price.Price, price.CreateDate)
-		if err != nil {
+	if err := finishBatch(ctx, tx, batchID, stats); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = recordBatchEvents(ctx, tx, tenantID, updatedIDs, models.BatchEventData{
+		BatchID:         batchID,
+		Mode:            opts.Mode,
+		InsertedCount:   stats.InsertedCount,
+		UpdatedCount:    stats.UpdatedCount,
+		UnchangedCount:  stats.UnchangedCount,
+		DuplicatesCount: stats.DuplicatesCount,
+		DeletedCount:    deleted,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if opts.IDStrategy == models.IDStrategyPreserve && stats.InsertedCount > 0 {
+		if err := resyncIDSequence(ctx, tx); err != nil {
 			tx.Rollback()
-			return nil, 0, fmt.Errorf("failed to insert price: %w", err)
+			return nil, err
 		}
-		insertedCount++
 	}
 
 	statsQuery := `
@@ -94,34 +110,358 @@ func (r *PriceRepository) InsertAndGetStats(prices []models.Price) (*models.Stat
 			COUNT(DISTINCT category) as total_categories,
 			COALESCE(SUM(price), 0) as total_price
 		FROM prices
+		WHERE tenant_id = $1
 	`
-	var stats models.Statistics
-	stats.TotalItems = insertedCount
-	err = tx.QueryRow(statsQuery).Scan(&stats.TotalCategories, &stats.TotalPrice)
+	stats.TotalItems = stats.InsertedCount + stats.UpdatedCount
+	queryCtx, span := startSpan(ctx, "SELECT prices statistics")
+	err = tx.QueryRowContext(queryCtx, statsQuery, tenantID).Scan(&stats.TotalCategories, &stats.TotalPrice)
+	tracing.End(span, err)
 	if err != nil {
 		tx.Rollback()
-		return nil, 0, fmt.Errorf("failed to get statistics: %w", err)
+		return nil, fmt.Errorf("failed to get statistics: %w", err)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	logging.FromContext(ctx).Debug("Prices saved",
+		"batch_id", batchID,
+		"rows", len(prices),
+		"inserted", stats.InsertedCount,
+		"updated", stats.UpdatedCount,
+		"unchanged", stats.UnchangedCount,
+		"duplicates", stats.DuplicatesCount,
+	)
+
+	return &stats, nil
+}
+
+func createBatch(ctx context.Context, tx *sql.Tx, tenantID string, rowCount int, opts models.ImportOptions) (int64, error) {
+	query := `
+		INSERT INTO upload_batches (tenant_id, subject, source, mode, id_strategy, row_count)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	var id int64
+	err := tx.QueryRowContext(ctx, query, tenantID, opts.Subject, opts.Source, opts.Mode, opts.IDStrategy, rowCount).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create upload batch: %w", err)
+	}
+	return id, nil
+}
+
+func finishBatch(ctx context.Context, tx *sql.Tx, batchID int64, stats models.ImportStats) error {
+	query := `
+		UPDATE upload_batches
+		SET inserted_count = $2, updated_count = $3, unchanged_count = $4, duplicates_count = $5
+		WHERE id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, batchID,
+		stats.InsertedCount, stats.UpdatedCount, stats.UnchangedCount, stats.DuplicatesCount)
+	if err != nil {
+		return fmt.Errorf("failed to update upload batch: %w", err)
+	}
+	return nil
+}
+
+// saveRows also returns the ids of the rows that were updated rather than
+// inserted.
+func saveRows(ctx context.Context, tx *sql.Tx, prices []models.Price, tenantID string, batchID int64, opts models.ImportOptions) (models.ImportStats, []int64, error) {
+	var stats models.ImportStats
+	var updatedIDs []int64
+
+	operation := "INSERT"
+	if opts.Mode == models.UploadModeUpsert {
+		operation = "UPSERT"
+	}
+
+	ctx, span := startSpan(ctx, operation+" prices",
+		attribute.String("db.operation.name", operation),
+		attribute.Int("prices.rows", len(prices)),
+	)
+
+	for _, price := range prices {
+		var outcome saveOutcome
+		var id int64
+		var err error
+		if opts.Mode == models.UploadModeUpsert {
+			outcome, id, err = upsertPrice(ctx, tx, price, tenantID, batchID, opts.IDStrategy, false)
+		} else {
+			outcome, err = insertPrice(ctx, tx, price, tenantID, batchID, opts.IDStrategy)
+		}
+		if err != nil {
+			tracing.End(span, err)
+			return stats, nil, err
+		}
+
+		switch outcome {
+		case outcomeInserted:
+			stats.InsertedCount++
+		case outcomeUpdated:
+			stats.UpdatedCount++
+			updatedIDs = append(updatedIDs, id)
+		case outcomeUnchanged:
+			stats.UnchangedCount++
+		case outcomeDuplicate:
+			stats.DuplicatesCount++
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("prices.inserted", stats.InsertedCount),
+		attribute.Int("prices.updated", stats.UpdatedCount),
+		attribute.Int("prices.unchanged", stats.UnchangedCount),
+		attribute.Int("prices.duplicates", stats.DuplicatesCount),
+	)
+	span.End()
+
+	return stats, updatedIDs, nil
+}
+
+type saveOutcome int
+
+const (
+	outcomeInserted saveOutcome = iota
+	outcomeUpdated
+	outcomeUnchanged
+	outcomeDuplicate
+)
+
+const uniqueViolation = "23505"
+
+// A collision on the natural key or the external id makes a row a
+// duplicate. A collision on the primary key does not: the id is usually
+// taken by another record, and the import fails.
+const (
+	naturalKeyIndex = "idx_prices_tenant_natural_key"
+	externalIDIndex = "idx_prices_tenant_external_id"
+	primaryKey      = "prices_pkey"
+)
+
+func isDuplicateRecord(err error) bool {
+	return isUniqueViolation(err, naturalKeyIndex, externalIDIndex)
+}
+
+func isUniqueViolation(err error, constraints ...string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && slices.Contains(constraints, pqErr.Constraint)
+}
+
+// holdsOwnID reports whether the id of a preserved row that collided on the
+// primary key belongs to the same record, which happens when another upload
+// of it committed after the statement started: Postgres checks unique
+// indexes other than the conflict target only then and raises an error.
+func holdsOwnID(ctx context.Context, tx *sql.Tx, tenantID string, id int) (bool, error) {
+	var externalID sql.NullInt64
+	err := tx.QueryRowContext(ctx,
+		"SELECT external_id FROM prices WHERE tenant_id = $1 AND id = $2", tenantID, id).Scan(&externalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up price %d: %w", id, err)
+	}
+	return externalID.Valid && externalID.Int64 == int64(id), nil
+}
+
+func insertPrice(ctx context.Context, tx *sql.Tx, price models.Price, tenantID string, batchID int64, strategy models.IDStrategy) (saveOutcome, error) {
+	var query string
+	if strategy == models.IDStrategyGenerate {
+		query = `INSERT INTO prices (external_id, name, category, price, create_date, batch_id, created_batch_id, tenant_id)
+		         VALUES ($1, $2, $3, $4, $5, $6, $6, $7)`
+	} else {
+		query = `INSERT INTO prices (id, external_id, name, category, price, create_date, batch_id, created_batch_id, tenant_id)
+		         VALUES ($1, $1, $2, $3, $4, $5, $6, $6, $7)`
+	}
+	// Concurrent uploads of the same rows wait on the natural key and skip
+	// them. Only one conflict target can be named, so a collision on the
+	// external id still raises an error and runs inside a savepoint.
+	query += `
+		ON CONFLICT (tenant_id, name, category, price, create_date) DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT save_price"); err != nil {
+		return 0, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, query, price.ID, price.Name, price.Category, price.Price, price.CreateDate, batchID, tenantID)
+	if isDuplicateRecord(err) {
+		return outcomeDuplicate, rollbackSavepoint(ctx, tx)
+	}
+	if isUniqueViolation(err, primaryKey) && strategy == models.IDStrategyPreserve {
+		if err := rollbackSavepoint(ctx, tx); err != nil {
+			return 0, err
+		}
+		own, err := holdsOwnID(ctx, tx, tenantID, price.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !own {
+			return 0, fmt.Errorf("%w: %d", ErrIDTaken, price.ID)
+		}
+		return outcomeDuplicate, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert price: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted rows: %w", err)
+	}
+
+	if affected == 0 {
+		return outcomeDuplicate, releaseSavepoint(ctx, tx)
+	}
+	return outcomeInserted, releaseSavepoint(ctx, tx)
+}
+
+// upsertPrice also returns the id of the row it inserted or updated.
+// retried is set when it runs again after a concurrent upload of the record.
+func upsertPrice(ctx context.Context, tx *sql.Tx, price models.Price, tenantID string, batchID int64, strategy models.IDStrategy, retried bool) (saveOutcome, int64, error) {
+	var query string
+	if strategy == models.IDStrategyGenerate {
+		query = `INSERT INTO prices (external_id, name, category, price, create_date, batch_id, created_batch_id, tenant_id)
+		         VALUES ($1, $2, $3, $4, $5, $6, $6, $7)`
+	} else {
+		query = `INSERT INTO prices (id, external_id, name, category, price, create_date, batch_id, created_batch_id, tenant_id)
+		         VALUES ($1, $1, $2, $3, $4, $5, $6, $6, $7)`
 	}
+	query += `
+		ON CONFLICT (tenant_id, external_id) DO UPDATE
+		SET name = EXCLUDED.name, category = EXCLUDED.category,
+		    price = EXCLUDED.price, create_date = EXCLUDED.create_date,
+		    batch_id = EXCLUDED.batch_id
+		WHERE (prices.name, prices.category, prices.price, prices.create_date)
+		      IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.category, EXCLUDED.price, EXCLUDED.create_date)
+		RETURNING id, (xmax = 0)`
+
+	// The row may still collide with another record on the natural key or
+	// the primary key, which aborts the statement, so it runs inside a
+	// savepoint.
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT save_price"); err != nil {
+		return 0, 0, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	var id int64
+	var inserted bool
+	err := tx.QueryRowContext(ctx, query, price.ID, price.Name, price.Category, price.Price, price.CreateDate, batchID, tenantID).Scan(&id, &inserted)
+
+	switch {
+	case isDuplicateRecord(err):
+		return outcomeDuplicate, 0, rollbackSavepoint(ctx, tx)
+	case isUniqueViolation(err, primaryKey) && strategy == models.IDStrategyPreserve && !retried:
+		if err := rollbackSavepoint(ctx, tx); err != nil {
+			return 0, 0, err
+		}
+		own, err := holdsOwnID(ctx, tx, tenantID, price.ID)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !own {
+			return 0, 0, fmt.Errorf("%w: %d", ErrIDTaken, price.ID)
+		}
+		// The record is now visible and is updated instead.
+		return upsertPrice(ctx, tx, price, tenantID, batchID, strategy, true)
+	case errors.Is(err, sql.ErrNoRows):
+		return outcomeUnchanged, 0, releaseSavepoint(ctx, tx)
+	case err != nil:
+		return 0, 0, fmt.Errorf("failed to upsert price: %w", err)
+	case inserted:
+		return outcomeInserted, id, releaseSavepoint(ctx, tx)
+	default:
+		return outcomeUpdated, id, releaseSavepoint(ctx, tx)
+	}
+}
+
+func rollbackSavepoint(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT save_price"); err != nil {
+		return fmt.Errorf("failed to roll back to savepoint: %w", err)
+	}
+	return releaseSavepoint(ctx, tx)
+}
 
-	return &stats, duplicatesCount, nil
+func releaseSavepoint(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT save_price"); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
 }
 
-func (r *PriceRepository) GetStatistics() (*models.Statistics, error) {
+// resyncIDSequence moves the id sequence past preserved ids. It never moves
+// the sequence back, because other tenants' rows may be invisible here.
+func resyncIDSequence(ctx context.Context, tx *sql.Tx) error {
+	query := `
+		WITH s AS (SELECT pg_get_serial_sequence('prices', 'id')::regclass AS seq),
+		     m AS (SELECT COALESCE(MAX(id), 0) AS max_id FROM prices)
+		SELECT setval(s.seq,
+		              GREATEST(m.max_id, COALESCE(pg_sequence_last_value(s.seq), 0), 1),
+		              m.max_id > 0 OR pg_sequence_last_value(s.seq) IS NOT NULL)
+		FROM s, m`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to resync id sequence: %w", err)
+	}
+	return nil
+}
+
+// DeletePrice reports false when no row has the given id.
+func (r *PriceRepository) DeletePrice(ctx context.Context, id int64) (bool, error) {
+	ctx, span := startSpan(ctx, "DELETE prices", attribute.Int64("prices.id", id))
+
+	deleted, err := r.deletePrice(ctx, id)
+	tracing.End(span, err)
+
+	return deleted, err
+}
+
+func (r *PriceRepository) deletePrice(ctx context.Context, id int64) (bool, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	affected, err := deletePrices(ctx, tx, tenantID, 0, " AND id = $3", id)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *PriceRepository) GetStatistics(ctx context.Context) (*models.Statistics, error) {
+	ctx, span := startSpan(ctx, "PriceRepository.GetStatistics")
+
+	stats, err := r.getStatistics(ctx)
+	tracing.End(span, err)
+
+	return stats, err
+}
+
+func (r *PriceRepository) getStatistics(ctx context.Context) (*models.Statistics, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	query := `
 		SELECT
 			COUNT(*) as total_items,
 			COUNT(DISTINCT category) as total_categories,
 			COALESCE(SUM(price), 0) as total_price
 		FROM prices
+		WHERE tenant_id = $1
 	`
 
 	var stats models.Statistics
-	err := r.db.QueryRow(query).Scan(&stats.TotalItems, &stats.TotalCategories, &stats.TotalPrice)
+	err = tx.QueryRowContext(ctx, query, tenantID).Scan(&stats.TotalItems, &stats.TotalCategories, &stats.TotalPrice)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get statistics: %w", err)
 	}
@@ -129,6 +469,36 @@ func (r *PriceRepository) GetStatistics() (*models.Statistics, error) {
 	return &stats, nil
 }
 
+// ExportVersion changes whenever the tenant's rows do: every write records a
+// newer batch and every delete lowers the row count. Exports cached in blob
+// storage are keyed by it.
+func (r *PriceRepository) ExportVersion(ctx context.Context) (string, error) {
+	ctx, span := startSpan(ctx, "PriceRepository.ExportVersion")
+
+	version, err := r.exportVersion(ctx)
+	tracing.End(span, err)
+
+	return version, err
+}
+
+func (r *PriceRepository) exportVersion(ctx context.Context) (string, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var rows, lastBatch int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(MAX(batch_id), 0) FROM prices WHERE tenant_id = $1",
+		tenantID).Scan(&rows, &lastBatch)
+	if err != nil {
+		return "", fmt.Errorf("failed to get export version: %w", err)
+	}
+
+	return fmt.Sprintf("%d-%d", lastBatch, rows), nil
+}
+
 type PriceFilter struct {
 	StartDate *string
 	EndDate   *string
@@ -136,10 +506,26 @@ type PriceFilter struct {
 	MaxPrice  *float64
 }
 
-func (r *PriceRepository) GetFilteredPrices(filter PriceFilter) ([]models.Price, error) {
-	query := "SELECT id, name, category, price, create_date FROM prices WHERE 1=1"
-	args := []interface{}{}
-	argIndex := 1
+func (r *PriceRepository) GetFilteredPrices(ctx context.Context, filter PriceFilter) ([]models.Price, error) {
+	ctx, span := startSpan(ctx, "PriceRepository.GetFilteredPrices")
+
+	prices, err := r.getFilteredPrices(ctx, filter)
+	span.SetAttributes(attribute.Int("prices.rows", len(prices)))
+	tracing.End(span, err)
+
+	return prices, err
+}
+
+func (r *PriceRepository) getFilteredPrices(ctx context.Context, filter PriceFilter) ([]models.Price, error) {
+	tx, tenantID, err := beginTx(ctx, r.db, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT id, name, category, price, create_date FROM prices WHERE tenant_id = $1"
+	args := []interface{}{tenantID}
+	argIndex := 2
 
 	if filter.StartDate != nil {
 		query += fmt.Sprintf(" AND create_date >= $%d", argIndex)
@@ -167,7 +553,7 @@ func (r *PriceRepository) GetFilteredPrices(filter PriceFilter) ([]models.Price,
 
 	query += " ORDER BY id"
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query prices: %w", err)
 	}
@@ -186,5 +572,12 @@ func (r *PriceRepository) GetFilteredPrices(filter PriceFilter) ([]models.Price,
 		return nil, fmt.Errorf("error iterating prices: %w", err)
 	}
 
+	logging.FromContext(ctx).Debug("Prices queried", "rows", len(prices))
+
 	return prices, nil
 }
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", "postgresql"))
+	return tracing.Start(ctx, name, attrs...)
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"project_sem/internal/models"
+	"project_sem/internal/tenant"
+	"project_sem/internal/testutil"
+)
+
+func testPrices(n int) []models.Price {
+	prices := make([]models.Price, n)
+	for i := range prices {
+		prices[i] = models.Price{
+			ID:         i + 1,
+			Name:       fmt.Sprintf("item%d", i+1),
+			Category:   "cat",
+			Price:      float64(i + 1),
+			CreateDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	return prices
+}
+
+func countPrices(t *testing.T, db *sql.DB, ctx context.Context) int {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM prices WHERE tenant_id = $1", tenant.FromContext(ctx)).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to count prices: %v", err)
+	}
+	return count
+}
+
+// TestConcurrentUploads saves the same rows from several transactions at
+// once and checks that every row is stored exactly once.
+func TestConcurrentUploads(t *testing.T) {
+	db := testutil.DB(t)
+
+	const rows, uploads = 50, 8
+	for _, mode := range []models.UploadMode{models.UploadModeInsertOnly, models.UploadModeUpsert} {
+		for _, strategy := range []models.IDStrategy{models.IDStrategyPreserve, models.IDStrategyGenerate} {
+			t.Run(fmt.Sprintf("%s/%s", mode, strategy), func(t *testing.T) {
+				ctx := testutil.Tenant(t)
+				repo := NewPriceRepository(db)
+				opts := models.ImportOptions{Mode: mode, IDStrategy: strategy}
+				prices := testPrices(rows)
+
+				start := make(chan struct{})
+				results := make([]*models.ImportStats, uploads)
+				errs := make([]error, uploads)
+				var wg sync.WaitGroup
+				for i := range uploads {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						<-start
+						results[i], errs[i] = repo.SaveAndGetStats(ctx, prices, opts)
+					}()
+				}
+				close(start)
+				wg.Wait()
+
+				var inserted, skipped int
+				for i := range uploads {
+					if errs[i] != nil {
+						t.Fatalf("upload %d failed: %v", i, errs[i])
+					}
+					stats := results[i]
+					if stats.UpdatedCount != 0 {
+						t.Errorf("upload %d updated %d rows of the same data", i, stats.UpdatedCount)
+					}
+					inserted += stats.InsertedCount
+					skipped += stats.DuplicatesCount + stats.UnchangedCount
+				}
+				if inserted != rows {
+					t.Errorf("inserted %d rows in total, want %d", inserted, rows)
+				}
+				if skipped != rows*(uploads-1) {
+					t.Errorf("skipped %d rows in total, want %d", skipped, rows*(uploads-1))
+				}
+				if count := countPrices(t, db, ctx); count != rows {
+					t.Errorf("table has %d rows, want %d", count, rows)
+				}
+			})
+		}
+	}
+}
+
+// TestInsertFailsOnTakenID checks that a preserved id held by another
+// record fails the upload instead of being counted as a duplicate.
+func TestInsertFailsOnTakenID(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := testutil.Tenant(t)
+	repo := NewPriceRepository(db)
+
+	// The external id stays clear of generated ids, which start at 1 in a
+	// new database.
+	generated := testPrices(1)
+	generated[0].ID = 2_000_000_000
+	if _, err := repo.SaveAndGetStats(ctx, generated, models.ImportOptions{Mode: models.UploadModeInsertOnly, IDStrategy: models.IDStrategyGenerate}); err != nil {
+		t.Fatalf("generate upload failed: %v", err)
+	}
+	var id int
+	if err := db.QueryRow("SELECT id FROM prices WHERE tenant_id = $1", tenant.FromContext(ctx)).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []models.UploadMode{models.UploadModeInsertOnly, models.UploadModeUpsert} {
+		other := []models.Price{{ID: id, Name: "other", Category: "cat", Price: 1, CreateDate: generated[0].CreateDate}}
+		_, err := repo.SaveAndGetStats(ctx, other, models.ImportOptions{Mode: mode, IDStrategy: models.IDStrategyPreserve})
+		if !errors.Is(err, ErrIDTaken) {
+			t.Errorf("%s upload of a taken id returned %v", mode, err)
+		}
+	}
+	if count := countPrices(t, db, ctx); count != 1 {
+		t.Errorf("table has %d rows, want 1", count)
+	}
+}
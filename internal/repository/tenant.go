@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"project_sem/internal/tenant"
+)
+
+// beginTx starts a transaction scoped to the tenant of ctx. The tenant is
+// published as app.tenant_id for the optional row-level security policies;
+// queries still filter on tenant_id themselves.
+func beginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*sql.Tx, string, error) {
+	tenantID := tenant.FromContext(ctx)
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenantID); err != nil {
+		tx.Rollback()
+		return nil, "", fmt.Errorf("failed to set tenant: %w", err)
+	}
+
+	return tx, tenantID, nil
+}
+
+var readOnly = &sql.TxOptions{ReadOnly: true}
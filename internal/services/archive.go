@@ -4,58 +4,158 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"project_sem/internal/metrics"
+	"project_sem/internal/tracing"
+)
+
+var (
+	ErrArchiveTooLarge   = errors.New("archive exceeds maximum size")
+	ErrEntryTooLarge     = errors.New("archive entry exceeds maximum decompressed size")
+	ErrExtractedTooLarge = errors.New("archive exceeds maximum decompressed size")
+	ErrCompressionRatio  = errors.New("archive entry exceeds maximum compression ratio")
+	ErrTooManyEntries    = errors.New("archive has too many entries")
+	ErrUnsafePath        = errors.New("archive entry has unsafe path")
 )
 
-type ArchiveService struct{}
+// ArchiveLimits bounds what Extract accepts. A zero value disables the
+// corresponding check.
+type ArchiveLimits struct {
+	MaxArchiveBytes   int64
+	MaxEntryBytes     int64
+	MaxExtractedBytes int64
+	MaxRatio          float64
+	MaxEntries        int
+}
+
+type ArchiveService struct {
+	limits ArchiveLimits
+}
+
+func NewArchiveService(limits ArchiveLimits) *ArchiveService {
+	return &ArchiveService{limits: limits}
+}
+
+// MaxArchiveBytes is the largest archive Extract accepts, 0 if unlimited.
+func (s *ArchiveService) MaxArchiveBytes() int64 {
+	return s.limits.MaxArchiveBytes
+}
+
+// IsLimitError reports whether err is a rejection by one of the archive
+// safety limits rather than a malformed archive.
+func IsLimitError(err error) bool {
+	for _, target := range []error{
+		ErrArchiveTooLarge, ErrEntryTooLarge, ErrExtractedTooLarge,
+		ErrCompressionRatio, ErrTooManyEntries, ErrUnsafePath,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ArchiveService) Extract(ctx context.Context, data []byte, archiveType string) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "ArchiveService.Extract",
+		attribute.String("archive.type", archiveType),
+		attribute.Int("archive.size_bytes", len(data)),
+	)
 
-func NewArchiveService() *ArchiveService {
-	return &ArchiveService{}
+	csvData, err := s.extract(ctx, data, archiveType)
+	span.SetAttributes(attribute.Int("csv.size_bytes", len(csvData)))
+	tracing.End(span, err)
+
+	if err != nil {
+		recordArchiveRejection(err)
+	}
+	return csvData, err
 }
 
-func (s *ArchiveService) Extract(data []byte, archiveType string) ([]byte, error) {
+func (s *ArchiveService) extract(ctx context.Context, data []byte, archiveType string) ([]byte, error) {
+	if max := s.limits.MaxArchiveBytes; max > 0 && int64(len(data)) > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrArchiveTooLarge, len(data), max)
+	}
+
 	switch archiveType {
 	case "zip":
-		return s.extractZip(data)
+		return s.extractZip(ctx, data)
 	case "tar":
-		return s.extractTar(data)
+		return s.extractTar(ctx, data)
+	case "csv":
+		return s.readEntry(bytes.NewReader(data), "csv")
 	default:
 		return nil, fmt.Errorf("unsupported archive type: %s", archiveType)
 	}
 }
 
-func (s *ArchiveService) extractZip(data []byte) ([]byte, error) {
+func (s *ArchiveService) extractZip(ctx context.Context, data []byte) ([]byte, error) {
 	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read zip archive: %w", err)
 	}
 
+	if max := s.limits.MaxEntries; max > 0 && len(reader.File) > max {
+		return nil, fmt.Errorf("%w: %d entries, limit %d", ErrTooManyEntries, len(reader.File), max)
+	}
+
+	// The central directory declares every size up front, so the whole
+	// archive is checked before anything is decompressed.
+	var total uint64
 	for _, file := range reader.File {
-		if strings.HasSuffix(strings.ToLower(file.Name), ".csv") {
-			f, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("failed to open csv file in zip: %w", err)
-			}
-			defer f.Close()
+		if err := s.checkEntry(file.Name, file.UncompressedSize64); err != nil {
+			return nil, err
+		}
+		if err := s.checkRatio(file.Name, file.CompressedSize64, file.UncompressedSize64); err != nil {
+			return nil, err
+		}
+		total += file.UncompressedSize64
+	}
+	if max := s.limits.MaxExtractedBytes; max > 0 && total > uint64(max) {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrExtractedTooLarge, total, max)
+	}
 
-			csvData, err := io.ReadAll(f)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read csv file from zip: %w", err)
-			}
+	for _, file := range reader.File {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-			return csvData, nil
+		if file.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(file.Name), ".csv") {
+			continue
 		}
+
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open csv file in zip: %w", err)
+		}
+		defer f.Close()
+
+		csvData, err := s.readEntry(f, file.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv file from zip: %w", err)
+		}
+
+		return csvData, nil
 	}
 
 	return nil, fmt.Errorf("no CSV file found in zip archive")
 }
 
-func (s *ArchiveService) extractTar(data []byte) ([]byte, error) {
+func (s *ArchiveService) extractTar(ctx context.Context, data []byte) ([]byte, error) {
 	tarReader := tar.NewReader(bytes.NewReader(data))
 
+	var entries int
+	var total int64
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		header, err := tarReader.Next()
 		if err == io.EOF {
 			break
@@ -64,7 +164,22 @@ func (s *ArchiveService) extractTar(data []byte) ([]byte, error) {
 			return nil, fmt.Errorf("failed to read tar archive: %w", err)
 		}
 
-		if header.Typeflag == tar.TypeDir {
+		entries++
+		if max := s.limits.MaxEntries; max > 0 && entries > max {
+			return nil, fmt.Errorf("%w: more than %d entries", ErrTooManyEntries, max)
+		}
+		if header.Size < 0 {
+			return nil, fmt.Errorf("failed to read tar archive: negative size of %s", header.Name)
+		}
+		if err := s.checkEntry(header.Name, uint64(header.Size)); err != nil {
+			return nil, err
+		}
+		total += header.Size
+		if max := s.limits.MaxExtractedBytes; max > 0 && total > max {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrExtractedTooLarge, max)
+		}
+
+		if header.Typeflag != tar.TypeReg {
 			continue
 		}
 
@@ -73,7 +188,7 @@ func (s *ArchiveService) extractTar(data []byte) ([]byte, error) {
 		}
 
 		if strings.HasSuffix(strings.ToLower(header.Name), ".csv") {
-			csvData, err := io.ReadAll(tarReader)
+			csvData, err := s.readEntry(tarReader, header.Name)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read csv file from tar: %w", err)
 			}
@@ -85,7 +200,89 @@ func (s *ArchiveService) extractTar(data []byte) ([]byte, error) {
 	return nil, fmt.Errorf("no CSV file found in tar archive")
 }
 
-func (s *ArchiveService) CreateZip(csvData []byte, filename string) ([]byte, error) {
+// checkEntry validates the name and declared size of an entry.
+func (s *ArchiveService) checkEntry(name string, size uint64) error {
+	if !isLocalPath(name) {
+		return fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+	if max := s.limits.MaxEntryBytes; max > 0 && size > uint64(max) {
+		return fmt.Errorf("%w: %s is %d bytes, limit %d", ErrEntryTooLarge, name, size, max)
+	}
+	return nil
+}
+
+func (s *ArchiveService) checkRatio(name string, compressed, uncompressed uint64) error {
+	max := s.limits.MaxRatio
+	if max <= 0 || uncompressed == 0 {
+		return nil
+	}
+	if compressed == 0 || float64(uncompressed)/float64(compressed) > max {
+		return fmt.Errorf("%w: %s expands from %d to %d bytes, limit %.0fx", ErrCompressionRatio, name, compressed, uncompressed, max)
+	}
+	return nil
+}
+
+// readEntry does not trust the declared size and stops reading as soon as
+// the entry limit is crossed.
+func (s *ArchiveService) readEntry(r io.Reader, name string) ([]byte, error) {
+	max := s.limits.MaxEntryBytes
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrEntryTooLarge, name, max)
+	}
+	return data, nil
+}
+
+// isLocalPath rejects absolute names and names escaping the archive root
+// with "..", using either slash as a separator.
+func isLocalPath(name string) bool {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func recordArchiveRejection(err error) {
+	reasons := map[error]string{
+		ErrArchiveTooLarge:   "archive_size",
+		ErrEntryTooLarge:     "entry_size",
+		ErrExtractedTooLarge: "extracted_size",
+		ErrCompressionRatio:  "ratio",
+		ErrTooManyEntries:    "entries",
+		ErrUnsafePath:        "path",
+	}
+	for target, reason := range reasons {
+		if errors.Is(err, target) {
+			metrics.ArchiveRejected.WithLabelValues(reason).Inc()
+			return
+		}
+	}
+}
+
+func (s *ArchiveService) CreateZip(ctx context.Context, csvData []byte, filename string) ([]byte, error) {
+	_, span := tracing.Start(ctx, "ArchiveService.CreateZip", attribute.Int("csv.size_bytes", len(csvData)))
+
+	zipData, err := s.createZip(csvData, filename)
+	span.SetAttributes(attribute.Int("archive.size_bytes", len(zipData)))
+	tracing.End(span, err)
+
+	return zipData, err
+}
+
+func (s *ArchiveService) createZip(csvData []byte, filename string) ([]byte, error) {
 	var buf bytes.Buffer
 	zipWriter := zip.NewWriter(&buf)
 
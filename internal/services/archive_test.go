@@ -0,0 +1,152 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testEntry struct {
+	name string
+	data string
+	// deflate compresses the entry in zip archives, which store it otherwise
+	// so that its ratio is 1.
+	deflate bool
+}
+
+func buildZip(t *testing.T, entries ...testEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		method := zip.Store
+		if entry.deflate {
+			method = zip.Deflate
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: entry.name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, entries ...testEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractLimits(t *testing.T) {
+	limits := ArchiveLimits{
+		MaxArchiveBytes:   1 << 20,
+		MaxEntryBytes:     1000,
+		MaxExtractedBytes: 1500,
+		MaxRatio:          10,
+		MaxEntries:        3,
+	}
+	csv := "id,name,category,price,create_date\n"
+	big := strings.Repeat("x", 800)
+
+	for _, format := range []struct {
+		name  string
+		build func(*testing.T, ...testEntry) []byte
+	}{
+		{"zip", buildZip},
+		{"tar", buildTar},
+	} {
+		for _, tc := range []struct {
+			name    string
+			entries []testEntry
+			want    error
+		}{
+			{"valid", []testEntry{{name: "data.csv", data: csv}}, nil},
+			{"entry too large", []testEntry{{name: "data.csv", data: strings.Repeat("x", 1001)}}, ErrEntryTooLarge},
+			{"extracted too large", []testEntry{{name: "a.txt", data: big}, {name: "data.csv", data: big}}, ErrExtractedTooLarge},
+			{"too many entries", []testEntry{{name: "a.txt"}, {name: "b.txt"}, {name: "c.txt"}, {name: "data.csv", data: csv}}, ErrTooManyEntries},
+			{"parent directory", []testEntry{{name: "../data.csv", data: csv}}, ErrUnsafePath},
+			{"nested parent directory", []testEntry{{name: "dir/../../data.csv", data: csv}}, ErrUnsafePath},
+			{"backslash parent directory", []testEntry{{name: `..\data.csv`, data: csv}}, ErrUnsafePath},
+			{"absolute path", []testEntry{{name: "/etc/data.csv", data: csv}}, ErrUnsafePath},
+			{"drive letter", []testEntry{{name: "C:/data.csv", data: csv}}, ErrUnsafePath},
+		} {
+			t.Run(format.name+"/"+tc.name, func(t *testing.T) {
+				s := NewArchiveService(limits)
+				data, err := s.Extract(context.Background(), format.build(t, tc.entries...), format.name)
+				if tc.want == nil {
+					if err != nil || string(data) != csv {
+						t.Fatalf("Extract returned %q, %v", data, err)
+					}
+					return
+				}
+				if !errors.Is(err, tc.want) {
+					t.Fatalf("Extract returned %v, want %v", err, tc.want)
+				}
+				if !IsLimitError(err) {
+					t.Errorf("IsLimitError(%v) = false", err)
+				}
+			})
+		}
+	}
+}
+
+func TestExtractCompressionRatio(t *testing.T) {
+	s := NewArchiveService(ArchiveLimits{MaxRatio: 10})
+
+	bomb := buildZip(t, testEntry{name: "data.csv", data: strings.Repeat("0", 100_000), deflate: true})
+	if _, err := s.Extract(context.Background(), bomb, "zip"); !errors.Is(err, ErrCompressionRatio) {
+		t.Fatalf("Extract returned %v, want ErrCompressionRatio", err)
+	}
+
+	stored := buildZip(t, testEntry{name: "data.csv", data: strings.Repeat("0", 100_000)})
+	if _, err := s.Extract(context.Background(), stored, "zip"); err != nil {
+		t.Fatalf("Extract of a stored entry returned %v", err)
+	}
+}
+
+func TestExtractArchiveTooLarge(t *testing.T) {
+	s := NewArchiveService(ArchiveLimits{MaxArchiveBytes: 100})
+	archive := buildZip(t, testEntry{name: "data.csv", data: strings.Repeat("x", 200)})
+
+	if _, err := s.Extract(context.Background(), archive, "zip"); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("Extract returned %v, want ErrArchiveTooLarge", err)
+	}
+}
+
+// TestExtractReadsAtMostTheEntryLimit checks the size of plain CSV uploads,
+// which declare none up front.
+func TestExtractReadsAtMostTheEntryLimit(t *testing.T) {
+	s := NewArchiveService(ArchiveLimits{MaxEntryBytes: 100})
+
+	if _, err := s.Extract(context.Background(), []byte(strings.Repeat("x", 101)), "csv"); !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("Extract returned %v, want ErrEntryTooLarge", err)
+	}
+	if _, err := s.Extract(context.Background(), []byte(strings.Repeat("x", 100)), "csv"); err != nil {
+		t.Fatalf("Extract at the limit returned %v", err)
+	}
+}
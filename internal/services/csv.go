@@ -2,12 +2,16 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"strconv"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"project_sem/internal/models"
+	"project_sem/internal/tracing"
 )
 
 type CSVService struct{}
@@ -25,7 +29,17 @@ type RawPriceRecord struct {
 	CreateDate string
 }
 
-func (s *CSVService) Parse(data []byte) ([]RawPriceRecord, int, error) {
+func (s *CSVService) Parse(ctx context.Context, data []byte) ([]RawPriceRecord, int, error) {
+	ctx, span := tracing.Start(ctx, "CSVService.Parse", attribute.Int("csv.size_bytes", len(data)))
+
+	records, totalCount, err := s.parse(ctx, data)
+	span.SetAttributes(attribute.Int("csv.rows", totalCount))
+	tracing.End(span, err)
+
+	return records, totalCount, err
+}
+
+func (s *CSVService) parse(ctx context.Context, data []byte) ([]RawPriceRecord, int, error) {
 	reader := csv.NewReader(bytes.NewReader(data))
 
 	header, err := reader.Read()
@@ -41,6 +55,10 @@ func (s *CSVService) Parse(data []byte) ([]RawPriceRecord, int, error) {
 	lineNumber := 1
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
 		row, err := reader.Read()
 		if err == io.EOF {
 			break
@@ -71,7 +89,17 @@ func (s *CSVService) Parse(data []byte) ([]RawPriceRecord, int, error) {
 	return records, totalCount, nil
 }
 
-func (s *CSVService) Generate(prices []models.Price) ([]byte, error) {
+func (s *CSVService) Generate(ctx context.Context, prices []models.Price) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "CSVService.Generate", attribute.Int("csv.rows", len(prices)))
+
+	data, err := s.generate(ctx, prices)
+	span.SetAttributes(attribute.Int("csv.size_bytes", len(data)))
+	tracing.End(span, err)
+
+	return data, err
+}
+
+func (s *CSVService) generate(ctx context.Context, prices []models.Price) ([]byte, error) {
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
 
@@ -81,6 +109,10 @@ func (s *CSVService) Generate(prices []models.Price) ([]byte, error) {
 	}
 
 	for _, p := range prices {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		row := []string{
 			strconv.Itoa(p.ID),
 			p.Name,
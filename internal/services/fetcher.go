@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"project_sem/internal/metrics"
+	"project_sem/internal/storage"
+	"project_sem/internal/tenant"
+	"project_sem/internal/tracing"
+)
+
+var (
+	ErrInvalidSource     = errors.New("invalid source URL")
+	ErrSourceNotAllowed  = errors.New("source not allowed")
+	ErrSourceNotFound    = errors.New("source not found")
+	ErrSourceUnavailable = errors.New("source unavailable")
+)
+
+// FetchOptions restricts where SourceFetcher may read from. Empty host and
+// root lists disable the http(s) and file schemes.
+type FetchOptions struct {
+	AllowedHosts []string
+	FileRoots    []string
+	Timeout      time.Duration
+	MaxBytes     int64
+}
+
+// SourceFetcher downloads archives for server-side imports from http(s)
+// URLs, local files and the blob storage (storage://key).
+type SourceFetcher struct {
+	opts   FetchOptions
+	store  storage.Storage
+	client *http.Client
+}
+
+func NewSourceFetcher(opts FetchOptions, store storage.Storage) *SourceFetcher {
+	f := &SourceFetcher{opts: opts, store: store}
+	f.client = &http.Client{
+		Timeout: opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !f.hostAllowed(req.URL) {
+				return fmt.Errorf("%w: redirect to %s", ErrSourceNotAllowed, req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// Fetch returns the content of rawURL and the file name it was published
+// under.
+func (f *SourceFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" {
+		return nil, "", fmt.Errorf("%w: %q", ErrInvalidSource, rawURL)
+	}
+
+	ctx, span := tracing.Start(ctx, "SourceFetcher.Fetch", attribute.String("source.scheme", u.Scheme))
+
+	data, err := f.fetch(ctx, u)
+	span.SetAttributes(attribute.Int("source.size_bytes", len(data)))
+	tracing.End(span, err)
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	scheme := u.Scheme
+	if !slices.Contains([]string{"http", "https", "file", "storage"}, scheme) {
+		scheme = "other"
+	}
+	metrics.SourceFetches.WithLabelValues(scheme, result).Inc()
+
+	return data, path.Base(u.Path), err
+}
+
+func (f *SourceFetcher) fetch(ctx context.Context, u *url.URL) ([]byte, error) {
+	if f.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.opts.Timeout)
+		defer cancel()
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return f.fetchHTTP(ctx, u)
+	case "file":
+		return f.fetchFile(ctx, u)
+	case "storage":
+		return f.fetchStorage(ctx, u)
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidSource, u.Scheme)
+	}
+}
+
+func (f *SourceFetcher) fetchHTTP(ctx context.Context, u *url.URL) ([]byte, error) {
+	if !f.hostAllowed(u) {
+		return nil, fmt.Errorf("%w: host %s", ErrSourceNotAllowed, u.Host)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSource, err)
+	}
+
+	resp, err := f.client.Do(req)
+	if errors.Is(err, ErrSourceNotAllowed) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSourceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, u.Redacted())
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %s returned %s", ErrSourceUnavailable, u.Redacted(), resp.Status)
+	}
+
+	if max := f.opts.MaxBytes; max > 0 && resp.ContentLength > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrArchiveTooLarge, resp.ContentLength, max)
+	}
+	return f.readLimited(resp.Body)
+}
+
+// fetchFile only reads files under the tenant's own directory of every
+// import root, <root>/<tenant>/.
+func (f *SourceFetcher) fetchFile(ctx context.Context, u *url.URL) ([]byte, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("%w: file URL with host %s", ErrInvalidSource, u.Host)
+	}
+	tenantID := tenant.FromContext(ctx)
+
+	// The unresolved path is checked first so that files outside the roots
+	// are rejected without revealing whether they exist.
+	name := filepath.Clean(filepath.FromSlash(u.Path))
+	if !f.underRoot(tenantID, name) {
+		return nil, fmt.Errorf("%w: %s is outside the import roots", ErrSourceNotAllowed, name)
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSourceUnavailable, err)
+	}
+	if !f.underRoot(tenantID, resolved) {
+		return nil, fmt.Errorf("%w: %s is outside the import roots", ErrSourceNotAllowed, name)
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSourceUnavailable, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSourceUnavailable, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidSource, name)
+	}
+	if max := f.opts.MaxBytes; max > 0 && info.Size() > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrArchiveTooLarge, info.Size(), max)
+	}
+	return f.readLimited(file)
+}
+
+// fetchStorage only reads the tenant's own objects under imports/ and
+// uploads/.
+func (f *SourceFetcher) fetchStorage(ctx context.Context, u *url.URL) ([]byte, error) {
+	if f.store == nil {
+		return nil, fmt.Errorf("%w: blob storage is not configured", ErrSourceNotAllowed)
+	}
+
+	key := strings.TrimPrefix(u.Host+u.Path, "/")
+	tenantID := tenant.FromContext(ctx)
+	if !storage.ValidKey(key) ||
+		!(strings.HasPrefix(key, "imports/"+tenantID+"/") || strings.HasPrefix(key, "uploads/"+tenantID+"/")) {
+		return nil, fmt.Errorf("%w: key %q", ErrSourceNotAllowed, key)
+	}
+
+	object, err := f.store.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSourceUnavailable, err)
+	}
+	if max := f.opts.MaxBytes; max > 0 && object.Size > max {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrArchiveTooLarge, object.Size, max)
+	}
+
+	body, err := f.store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSourceUnavailable, err)
+	}
+	defer body.Close()
+	return f.readLimited(body)
+}
+
+// readLimited does not trust declared sizes and stops reading once the
+// limit is crossed.
+func (f *SourceFetcher) readLimited(r io.Reader) ([]byte, error) {
+	max := f.opts.MaxBytes
+	if max <= 0 {
+		max = 1<<63 - 2
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSourceUnavailable, err)
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, max)
+	}
+	return data, nil
+}
+
+func (f *SourceFetcher) hostAllowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	return slices.Contains(f.opts.AllowedHosts, "*") || slices.Contains(f.opts.AllowedHosts, u.Hostname())
+}
+
+func (f *SourceFetcher) underRoot(tenantID, name string) bool {
+	if !tenant.Valid(tenantID) {
+		return false
+	}
+	for _, root := range f.opts.FileRoots {
+		root = filepath.Join(root, tenantID)
+		candidates := []string{root}
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			candidates = append(candidates, resolved)
+		}
+		for _, root := range candidates {
+			rel, err := filepath.Rel(root, name)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"project_sem/internal/tenant"
+)
+
+// TestFetchFileRoots checks that file:// imports only read the tenant's own
+// directory of the import root.
+func TestFetchFileRoots(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"acme/data.zip", "other/data.zip", "shared.zip"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "other", "data.zip"), filepath.Join(root, "acme", "link.zip")); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewSourceFetcher(FetchOptions{FileRoots: []string{root}}, nil)
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	data, filename, err := f.Fetch(ctx, "file://"+filepath.Join(root, "acme", "data.zip"))
+	if err != nil || string(data) != "acme/data.zip" || filename != "data.zip" {
+		t.Fatalf("Fetch of an own file returned %q, %q, %v", data, filename, err)
+	}
+
+	for name, path := range map[string]string{
+		"other tenant":       filepath.Join(root, "other", "data.zip"),
+		"root itself":        filepath.Join(root, "shared.zip"),
+		"link to other":      filepath.Join(root, "acme", "link.zip"),
+		"dot dot":            filepath.Join(root, "acme") + "/../other/data.zip",
+		"missing elsewhere":  filepath.Join(root, "other", "missing.zip"),
+		"outside every root": "/etc/passwd",
+	} {
+		if _, _, err := f.Fetch(ctx, "file://"+path); !errors.Is(err, ErrSourceNotAllowed) {
+			t.Errorf("%s: Fetch returned %v, want ErrSourceNotAllowed", name, err)
+		}
+	}
+
+	if _, _, err := f.Fetch(ctx, "file://"+filepath.Join(root, "acme", "missing.zip")); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("Fetch of a missing own file returned %v, want ErrSourceNotFound", err)
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"project_sem/internal/logging"
+	"project_sem/internal/metrics"
+	"project_sem/internal/models"
+	"project_sem/internal/repository"
+	"project_sem/internal/tracing"
+)
+
+var (
+	ErrCorruptedArchive = errors.New("corrupted archive")
+	ErrInvalidCSV       = errors.New("invalid CSV format")
+)
+
+type ImportService struct {
+	archiveService   *ArchiveService
+	csvService       *CSVService
+	validatorService *ValidatorService
+	repo             *repository.PriceRepository
+	webhooks         *WebhookService
+}
+
+func NewImportService(
+	archiveService *ArchiveService,
+	csvService *CSVService,
+	validatorService *ValidatorService,
+	repo *repository.PriceRepository,
+	webhooks *WebhookService,
+) *ImportService {
+	return &ImportService{
+		archiveService:   archiveService,
+		csvService:       csvService,
+		validatorService: validatorService,
+		repo:             repo,
+		webhooks:         webhooks,
+	}
+}
+
+func (s *ImportService) Import(ctx context.Context, data []byte, archiveType string, opts models.ImportOptions) (*models.UploadResponse, error) {
+	ctx, span := tracing.Start(ctx, "ImportService.Import",
+		attribute.String("import.mode", string(opts.Mode)),
+		attribute.String("import.id_strategy", string(opts.IDStrategy)),
+	)
+
+	response, err := s.importData(ctx, data, archiveType, opts)
+	tracing.End(span, err)
+
+	s.webhooks.ImportFinished(ctx, opts, response, err)
+
+	return response, err
+}
+
+func (s *ImportService) importData(ctx context.Context, data []byte, archiveType string, opts models.ImportOptions) (*models.UploadResponse, error) {
+	metrics.ArchiveSize.WithLabelValues("upload").Observe(float64(len(data)))
+
+	csvData, err := s.archiveService.Extract(ctx, data, archiveType)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if IsLimitError(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptedArchive, err)
+	}
+
+	rawRecords, totalCount, err := s.csvService.Parse(ctx, csvData)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+	}
+
+	validationResult, err := s.validatorService.Validate(ctx, rawRecords, totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.repo.SaveAndGetStats(ctx, validationResult.ValidRecords, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.UploadResponse{
+		TotalCount:      validationResult.TotalCount,
+		DuplicatesCount: validationResult.DuplicatesCount + stats.DuplicatesCount,
+		InsertedCount:   stats.InsertedCount,
+		UpdatedCount:    stats.UpdatedCount,
+		UnchangedCount:  stats.UnchangedCount,
+		TotalItems:      stats.TotalItems,
+		TotalCategories: stats.TotalCategories,
+		TotalPrice:      stats.TotalPrice,
+		BatchID:         stats.BatchID,
+	}
+
+	recordUploadRows(response)
+
+	logging.FromContext(ctx).Info("Import completed",
+		"batch_id", response.BatchID,
+		"subject", opts.Subject,
+		"source", opts.Source,
+		"archive_type", archiveType,
+		"mode", opts.Mode,
+		"id_strategy", opts.IDStrategy,
+		"total_count", response.TotalCount,
+		"inserted_count", response.InsertedCount,
+		"updated_count", response.UpdatedCount,
+		"unchanged_count", response.UnchangedCount,
+		"duplicates_count", response.DuplicatesCount,
+	)
+
+	return response, nil
+}
+
+// recordUploadRows splits the parsed rows into valid, duplicate and rejected
+// ones. Duplicates, whether within the file or of stored records, are only
+// counted as duplicate.
+func recordUploadRows(response *models.UploadResponse) {
+	valid := response.InsertedCount + response.UpdatedCount + response.UnchangedCount
+
+	metrics.UploadRows.WithLabelValues("parsed").Add(float64(response.TotalCount))
+	metrics.UploadRows.WithLabelValues("valid").Add(float64(valid))
+	metrics.UploadRows.WithLabelValues("rejected").Add(float64(response.TotalCount - valid - response.DuplicatesCount))
+	metrics.UploadRows.WithLabelValues("duplicate").Add(float64(response.DuplicatesCount))
+}
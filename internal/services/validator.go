@@ -1,20 +1,21 @@
 package services
 
 import (
+	"context"
 	"strconv"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"project_sem/internal/models"
-	"project_sem/internal/repository"
+	"project_sem/internal/tracing"
 )
 
-type ValidatorService struct {
-	repo *repository.PriceRepository
-}
+type ValidatorService struct{}
 
-func NewValidatorService(repo *repository.PriceRepository) *ValidatorService {
-	return &ValidatorService{repo: repo}
+func NewValidatorService() *ValidatorService {
+	return &ValidatorService{}
 }
 
 type ValidationResult struct {
@@ -23,7 +24,22 @@ type ValidationResult struct {
 	DuplicatesCount int
 }
 
-func (v *ValidatorService) Validate(rawRecords []RawPriceRecord, totalCount int) (*ValidationResult, error) {
+func (v *ValidatorService) Validate(ctx context.Context, rawRecords []RawPriceRecord, totalCount int) (*ValidationResult, error) {
+	ctx, span := tracing.Start(ctx, "ValidatorService.Validate", attribute.Int("csv.rows", len(rawRecords)))
+
+	result, err := v.validate(ctx, rawRecords, totalCount)
+	if result != nil {
+		span.SetAttributes(
+			attribute.Int("validation.valid", len(result.ValidRecords)),
+			attribute.Int("validation.duplicates", result.DuplicatesCount),
+		)
+	}
+	tracing.End(span, err)
+
+	return result, err
+}
+
+func (v *ValidatorService) validate(ctx context.Context, rawRecords []RawPriceRecord, totalCount int) (*ValidationResult, error) {
 	result := &ValidationResult{
 		ValidRecords:    make([]models.Price, 0),
 		TotalCount:      totalCount,
@@ -31,10 +47,12 @@ func (v *ValidatorService) Validate(rawRecords []RawPriceRecord, totalCount int)
 	}
 
 	seenIDs := make(map[int]bool)
-	validIDs := make([]int, 0)
-	tempValidRecords := make([]models.Price, 0)
 
 	for _, raw := range rawRecords {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		if strings.TrimSpace(raw.ID) == "" ||
 			strings.TrimSpace(raw.Name) == "" ||
 			strings.TrimSpace(raw.Category) == "" ||
@@ -64,7 +82,6 @@ func (v *ValidatorService) Validate(rawRecords []RawPriceRecord, totalCount int)
 		}
 
 		seenIDs[id] = true
-		validIDs = append(validIDs, id)
 
 		validRecord := models.Price{
 			ID:         id,
@@ -74,20 +91,7 @@ func (v *ValidatorService) Validate(rawRecords []RawPriceRecord, totalCount int)
 			CreateDate: createDate,
 		}
 
-		tempValidRecords = append(tempValidRecords, validRecord)
-	}
-
-	existingMap, err := v.repo.CheckExistingIDs(validIDs)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, record := range tempValidRecords {
-		if existingMap[record.ID] {
-			result.DuplicatesCount++
-		} else {
-			result.ValidRecords = append(result.ValidRecords, record)
-		}
+		result.ValidRecords = append(result.ValidRecords, validRecord)
 	}
 
 	return result, nil
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"project_sem/internal/logging"
+	"project_sem/internal/metrics"
+	"project_sem/internal/models"
+	"project_sem/internal/repository"
+	"project_sem/internal/tenant"
+	"project_sem/internal/version"
+)
+
+// webhookSecretPrefix marks signing secrets issued by this service.
+const webhookSecretPrefix = "whsec_"
+
+// Headers of webhook requests. The signature is "sha256=" followed by the
+// hex HMAC-SHA256 of the timestamp, a dot and the body, keyed by the secret.
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	webhookClaimBatch = 20
+	webhookPurgeEvery = time.Hour
+	webhookErrorBytes = 512
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+type WebhookOptions struct {
+	Timeout       time.Duration
+	MaxAttempts   int
+	RetryInitial  time.Duration
+	RetryMaxDelay time.Duration
+	// Retention is how long finished deliveries stay in the log, zero to
+	// keep them forever.
+	Retention time.Duration
+}
+
+// WebhookService registers webhooks, records import events for them and
+// delivers the events from the database, so that pending deliveries survive
+// restarts and are shared between replicas.
+type WebhookService struct {
+	repo   *repository.WebhookRepository
+	opts   WebhookOptions
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, opts WebhookOptions) *WebhookService {
+	return &WebhookService{
+		repo: repo,
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			// A redirect is reported as a failed attempt rather than followed,
+			// so the payload only goes to the registered URL.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// SignWebhook returns the signature header value of body sent at timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the signature headers of a received webhook request
+// and rejects timestamps further than tolerance from now.
+func VerifyWebhook(secret string, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp is %s away from now", age.Round(time.Second))
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(WebhookSignatureHeader))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// Create registers url for events of the tenant of ctx, or for every event
+// when none are given.
+func (s *WebhookService) Create(ctx context.Context, rawURL string, events []string) (*models.CreatedWebhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(events) == 0 {
+		events = []string{models.EventImportCompleted, models.EventImportFailed}
+	}
+	for _, event := range events {
+		if !models.ValidWebhookEvent(event) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	key := webhookSecretPrefix + hex.EncodeToString(secret)
+
+	webhook, err := s.repo.Create(ctx, u.String(), key, events)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CreatedWebhook{Secret: key, Webhook: *webhook}, nil
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]models.Webhook, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id int64) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *WebhookService) Deliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error) {
+	return s.repo.Deliveries(ctx, webhookID, limit)
+}
+
+// ImportFinished schedules deliveries of an import.completed or
+// import.failed event. Imports abandoned because the client went away are
+// not reported. Failing to schedule is logged and does not affect the import.
+func (s *WebhookService) ImportFinished(ctx context.Context, opts models.ImportOptions, response *models.UploadResponse, importErr error) {
+	if errors.Is(importErr, context.Canceled) {
+		return
+	}
+
+	payload := models.WebhookPayload{
+		Event:      models.EventImportCompleted,
+		Tenant:     tenant.FromContext(ctx),
+		Subject:    opts.Subject,
+		Source:     opts.Source,
+		Mode:       opts.Mode,
+		IDStrategy: opts.IDStrategy,
+		OccurredAt: time.Now().UTC(),
+	}
+	if importErr != nil {
+		payload.Event = models.EventImportFailed
+		payload.Error = webhookError(importErr)
+	} else {
+		payload.BatchID = response.BatchID
+		payload.Response = response
+	}
+
+	logger := logging.FromContext(ctx)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("Failed to encode webhook payload", "error", err)
+		return
+	}
+
+	// The request context may already be past its deadline when the import
+	// failed because of it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	scheduled, err := s.repo.Enqueue(ctx, payload.Event, body)
+	if err != nil {
+		logger.Error("Failed to schedule webhook deliveries", "event", payload.Event, "error", err)
+		return
+	}
+	if scheduled > 0 {
+		logger.Info("Webhook deliveries scheduled", "event", payload.Event, "deliveries", scheduled)
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// webhookError only passes on messages about the uploaded data; other
+// failures are internal and described generically.
+func webhookError(err error) string {
+	switch {
+	case errors.Is(err, ErrCorruptedArchive), errors.Is(err, ErrInvalidCSV), errors.Is(err, repository.ErrIDTaken), IsLimitError(err):
+		return err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "import timed out"
+	default:
+		return "internal error"
+	}
+}
+
+// Run delivers due events every interval, and right away after an import in
+// this process scheduled some, until ctx is done.
+func (s *WebhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		s.deliverDue(ctx)
+
+		if s.opts.Retention > 0 && time.Since(lastPurge) >= webhookPurgeEvery {
+			lastPurge = time.Now()
+			purged, err := s.repo.PurgeDeliveries(ctx, lastPurge.Add(-s.opts.Retention))
+			if err != nil && ctx.Err() == nil {
+				slog.Error("Failed to purge webhook deliveries", "error", err)
+			} else if purged > 0 {
+				slog.Info("Webhook deliveries purged", "count", purged)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	// The lease outlives the request timeout so that a delivery is only
+	// claimed again once the attempt holding it has certainly ended.
+	lease := s.opts.Timeout + 30*time.Second
+
+	for ctx.Err() == nil {
+		due, err := s.repo.ClaimDue(ctx, webhookClaimBatch, lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Failed to claim webhook deliveries", "error", err)
+			}
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range due {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.deliver(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if len(due) < webhookClaimBatch {
+			return
+		}
+	}
+}
+
+func (s *WebhookService) deliver(ctx context.Context, delivery repository.DueDelivery) {
+	logger := slog.With("delivery_id", delivery.ID, "event", delivery.Event)
+
+	statusCode, err := s.send(ctx, delivery)
+	if ctx.Err() != nil {
+		// Shutting down: the attempt is not counted and the delivery is
+		// claimed again once its lease expires.
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	status := models.DeliveryStatusDelivered
+	var errMsg string
+	var retryAt time.Time
+	if err != nil {
+		errMsg = err.Error()
+		status = models.DeliveryStatusPending
+		retryAt = time.Now().Add(s.backoff(attempts))
+		if attempts >= s.opts.MaxAttempts {
+			status = models.DeliveryStatusFailed
+		}
+	}
+
+	if err := s.repo.RecordAttempt(ctx, delivery.ID, status, statusCode, errMsg, retryAt); err != nil {
+		logger.Error("Failed to record webhook delivery", "error", err)
+	}
+
+	switch status {
+	case models.DeliveryStatusDelivered:
+		metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+		logger.Info("Webhook delivered", "attempts", attempts, "status_code", statusCode)
+	case models.DeliveryStatusPending:
+		metrics.WebhookDeliveries.WithLabelValues("retry").Inc()
+		logger.Warn("Webhook delivery failed, retrying",
+			"attempts", attempts, "retry_at", retryAt, "status_code", statusCode, "error", errMsg)
+	default:
+		metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+		logger.Error("Webhook delivery failed, giving up",
+			"attempts", attempts, "status_code", statusCode, "error", errMsg)
+	}
+}
+
+// send posts the payload and returns the response status, or zero when no
+// response was received. Any status outside 2xx is an error.
+func (s *WebhookService) send(ctx context.Context, delivery repository.DueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "prices-api-webhooks/"+version.Version)
+	req.Header.Set(WebhookIDHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		if text := strings.TrimSpace(string(body)); text != "" {
+			msg += ": " + text
+		}
+		return resp.StatusCode, errors.New(msg)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the delay from RetryInitial with every attempt up to
+// RetryMaxDelay and picks a random duration between half of it and all of it.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.opts.RetryInitial
+	for i := 1; i < attempts && delay < s.opts.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.opts.RetryMaxDelay)
+
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + mathrand.N(delay-half)
+}
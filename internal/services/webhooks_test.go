@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"project_sem/internal/models"
+	"project_sem/internal/repository"
+	"project_sem/internal/testutil"
+)
+
+// webhookReceiver records the requests of every path, checks their
+// signature when it knows the secret of the path and answers them with the
+// status the path names.
+type webhookReceiver struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	secrets  map[string]string
+	requests map[string][]time.Time
+}
+
+func newWebhookReceiver(t *testing.T) (*webhookReceiver, *httptest.Server) {
+	t.Helper()
+
+	receiver := &webhookReceiver{t: t, secrets: make(map[string]string), requests: make(map[string][]time.Time)}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	return receiver, server
+}
+
+func (rv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rv.mu.Lock()
+	rv.requests[r.URL.Path] = append(rv.requests[r.URL.Path], time.Now())
+	secret := rv.secrets[r.URL.Path]
+	rv.mu.Unlock()
+
+	if secret != "" {
+		if err := VerifyWebhook(secret, r.Header, body, time.Now(), time.Minute); err != nil {
+			rv.t.Errorf("%s: signature rejected: %v", r.URL.Path, err)
+		}
+	}
+
+	switch r.URL.Path {
+	case "/ok":
+		w.WriteHeader(http.StatusNoContent)
+	case "/fail":
+		http.Error(w, "boom", http.StatusInternalServerError)
+	case "/redirect":
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (rv *webhookReceiver) setSecret(path, secret string) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	rv.secrets[path] = secret
+}
+
+func (rv *webhookReceiver) times(path string) []time.Time {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	return append([]time.Time(nil), rv.requests[path]...)
+}
+
+func TestSendSignsRequests(t *testing.T) {
+	const secret = "whsec_test"
+	receiver, server := newWebhookReceiver(t)
+	receiver.setSecret("/ok", secret)
+	s := NewWebhookService(nil, WebhookOptions{Timeout: 5 * time.Second})
+
+	delivery := repository.DueDelivery{
+		ID:      7,
+		Event:   models.EventImportCompleted,
+		Payload: []byte(`{"event":"import.completed"}`),
+		URL:     server.URL + "/ok",
+		Secret:  secret,
+	}
+	status, err := s.send(context.Background(), delivery)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("send returned %d, %v", status, err)
+	}
+	if len(receiver.times("/ok")) != 1 {
+		t.Errorf("receiver got %d requests, want 1", len(receiver.times("/ok")))
+	}
+
+	delivery.URL = server.URL + "/fail"
+	status, err = s.send(context.Background(), delivery)
+	if status != http.StatusInternalServerError || err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("send to a failing receiver returned %d, %v", status, err)
+	}
+
+	// The redirect is not followed, so the target only got the first request.
+	delivery.URL = server.URL + "/redirect"
+	status, err = s.send(context.Background(), delivery)
+	if status != http.StatusFound || err == nil {
+		t.Errorf("send to a redirect returned %d, %v", status, err)
+	}
+	if len(receiver.times("/ok")) != 1 {
+		t.Errorf("redirect was followed")
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"event":"import.failed"}`)
+	now := time.Now()
+
+	header := http.Header{}
+	header.Set(WebhookTimestampHeader, "1700000000")
+	header.Set(WebhookSignatureHeader, SignWebhook(secret, 1700000000, body))
+	sentAt := time.Unix(1700000000, 0)
+
+	if err := VerifyWebhook(secret, header, body, sentAt.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := VerifyWebhook(secret, header, []byte(`{"event":"import.completed"}`), sentAt, 5*time.Minute); err == nil {
+		t.Error("signature of another body accepted")
+	}
+	if err := VerifyWebhook("whsec_other", header, body, sentAt, 5*time.Minute); err == nil {
+		t.Error("signature with another secret accepted")
+	}
+	if err := VerifyWebhook(secret, header, body, now, 5*time.Minute); err == nil {
+		t.Error("stale timestamp accepted")
+	}
+
+	header.Del(WebhookTimestampHeader)
+	if err := VerifyWebhook(secret, header, body, sentAt, 5*time.Minute); err == nil {
+		t.Error("request without a timestamp accepted")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := NewWebhookService(nil, WebhookOptions{RetryInitial: time.Second, RetryMaxDelay: 10 * time.Second})
+
+	for attempts, delay := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		20: 10 * time.Second,
+	} {
+		for range 100 {
+			got := s.backoff(attempts)
+			if got < delay/2 || got > delay {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempts, got, delay/2, delay)
+			}
+		}
+	}
+}
+
+// TestDeliverRetries runs deliveries of one event to a receiver that
+// accepts it, one that fails with 500 and one that redirects, and follows
+// the delivery log after every round.
+func TestDeliverRetries(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := testutil.Tenant(t)
+
+	const maxAttempts = 3
+	repo := repository.NewWebhookRepository(db)
+	s := NewWebhookService(repo, WebhookOptions{
+		Timeout:       5 * time.Second,
+		MaxAttempts:   maxAttempts,
+		RetryInitial:  200 * time.Millisecond,
+		RetryMaxDelay: time.Second,
+	})
+
+	receiver, server := newWebhookReceiver(t)
+	webhooks := map[string]*models.CreatedWebhook{}
+	for _, path := range []string{"/ok", "/fail", "/redirect"} {
+		created, err := s.Create(ctx, server.URL+path, nil)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		webhooks[path] = created
+		receiver.setSecret(path, created.Secret)
+	}
+
+	s.ImportFinished(ctx, models.ImportOptions{Mode: models.UploadModeInsertOnly}, &models.UploadResponse{BatchID: 1, TotalCount: 3}, nil)
+
+	// history holds the attempts and status of every delivery, starting
+	// before the first round and then after each round that changed them.
+	type state struct {
+		attempts int
+		status   string
+	}
+	history := map[string][]state{}
+	deadline := time.Now().Add(20 * time.Second)
+	for round := 0; ; round++ {
+		if round > 0 {
+			s.deliverDue(ctx)
+		}
+
+		done := true
+		for path, webhook := range webhooks {
+			deliveries, err := s.Deliveries(ctx, webhook.Webhook.ID, 10)
+			if err != nil {
+				t.Fatalf("Deliveries: %v", err)
+			}
+			if len(deliveries) != 1 {
+				t.Fatalf("%s has %d deliveries, want 1", path, len(deliveries))
+			}
+			d := deliveries[0]
+			current := state{d.Attempts, d.Status}
+			if n := len(history[path]); n == 0 || history[path][n-1] != current {
+				history[path] = append(history[path], current)
+			}
+			if d.Status == models.DeliveryStatusPending {
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries did not finish: %v", history)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	want := map[string][]state{
+		"/ok": {{0, models.DeliveryStatusPending}, {1, models.DeliveryStatusDelivered}},
+		"/fail": {{0, models.DeliveryStatusPending}, {1, models.DeliveryStatusPending},
+			{2, models.DeliveryStatusPending}, {3, models.DeliveryStatusFailed}},
+		"/redirect": {{0, models.DeliveryStatusPending}, {1, models.DeliveryStatusPending},
+			{2, models.DeliveryStatusPending}, {3, models.DeliveryStatusFailed}},
+	}
+	for path, states := range want {
+		got := history[path]
+		if len(got) != len(states) {
+			t.Errorf("%s went through %v, want %v", path, got, states)
+			continue
+		}
+		for i := range states {
+			if got[i] != states[i] {
+				t.Errorf("%s went through %v, want %v", path, got, states)
+				break
+			}
+		}
+	}
+
+	for path, code := range map[string]int{"/ok": http.StatusNoContent, "/fail": http.StatusInternalServerError, "/redirect": http.StatusFound} {
+		deliveries, _ := s.Deliveries(ctx, webhooks[path].Webhook.ID, 1)
+		d := deliveries[0]
+		if d.LastStatusCode == nil || *d.LastStatusCode != code {
+			t.Errorf("%s last_status_code = %v, want %d", path, d.LastStatusCode, code)
+		}
+		if path == "/ok" && (d.DeliveredAt == nil || d.LastError != nil) {
+			t.Errorf("%s delivered_at = %v, last_error = %v", path, d.DeliveredAt, d.LastError)
+		}
+		if path != "/ok" && d.LastError == nil {
+			t.Errorf("%s has no last_error", path)
+		}
+	}
+
+	// Every attempt reached the receiver, and retries waited for at least
+	// half of their backoff delay.
+	if got := len(receiver.times("/ok")); got != 1 {
+		t.Errorf("/ok received %d requests, want 1", got)
+	}
+	for _, path := range []string{"/fail", "/redirect"} {
+		times := receiver.times(path)
+		if len(times) != maxAttempts {
+			t.Errorf("%s received %d requests, want %d", path, len(times), maxAttempts)
+			continue
+		}
+		for i := 1; i < len(times); i++ {
+			minDelay := (s.opts.RetryInitial << (i - 1)) / 2
+			if gap := times[i].Sub(times[i-1]); gap < minDelay {
+				t.Errorf("%s attempt %d came %s after the previous one, want at least %s", path, i+1, gap, minDelay)
+			}
+		}
+	}
+}
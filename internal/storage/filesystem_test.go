@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilesystem(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "blobs")
+	s, err := NewFilesystem(root)
+	if err != nil {
+		t.Fatalf("NewFilesystem: %v", err)
+	}
+
+	const key = "uploads/default/1/data.zip"
+	if err := s.Put(ctx, key, strings.NewReader("archive"), 7, "application/zip"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "archive" {
+		t.Fatalf("Get returned %q, %v", data, err)
+	}
+
+	object, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if object.Key != key || object.Size != 7 {
+		t.Errorf("Stat returned %+v", object)
+	}
+
+	if err := s.Put(ctx, key, strings.NewReader("replaced"), 8, "application/zip"); err != nil {
+		t.Fatalf("Put over an existing object: %v", err)
+	}
+	if object, err := s.Stat(ctx, key); err != nil || object.Size != 8 {
+		t.Errorf("Stat after overwrite returned %+v, %v", object, err)
+	}
+
+	// A leftover temporary file is not an object.
+	if err := os.WriteFile(filepath.Join(root, "uploads", ".upload-123"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "exports/default/a.zip", strings.NewReader("x"), 1, "application/zip"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	objects, err := s.List(ctx, "uploads/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != key {
+		t.Errorf("List(uploads/) returned %+v", objects)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+	}
+	if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete: %v, want ErrNotFound", err)
+	}
+
+	if _, err := s.PresignGet(ctx, key, "data.zip", time.Minute); !errors.Is(err, ErrPresignUnsupported) {
+		t.Errorf("PresignGet: %v, want ErrPresignUnsupported", err)
+	}
+}
+
+func TestFilesystemInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewFilesystem(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatalf("NewFilesystem: %v", err)
+	}
+
+	for _, key := range []string{"", ".", "..", "../escape", "/etc/passwd", "a/../../escape", "a//b", "a/./b", "a/", `a\b`} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := s.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want an invalid key error", key, err)
+		}
+		if _, err := s.Stat(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat(%q) = %v, want an invalid key error", key, err)
+		}
+		if err := s.Delete(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete(%q) = %v, want an invalid key error", key, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "escape")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a file was written outside the storage root")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := NewFilesystem(root)
+	if err != nil {
+		t.Fatalf("NewFilesystem: %v", err)
+	}
+
+	now := time.Now()
+	for key, age := range map[string]time.Duration{
+		"exports/default/old.zip": 3 * time.Hour,
+		"exports/default/new.zip": time.Minute,
+		"uploads/default/old.zip": 3 * time.Hour,
+	} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, "application/zip"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		modTime := now.Add(-age)
+		if err := os.Chtimes(s.path(key), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := purge(ctx, s, "exports/", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d objects, want 1", purged)
+	}
+	if _, err := s.Stat(ctx, "exports/default/old.zip"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired export was kept: %v", err)
+	}
+	for _, key := range []string{"exports/default/new.zip", "uploads/default/old.zip"} {
+		if _, err := s.Stat(ctx, key); err != nil {
+			t.Errorf("%s was purged: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidLink = errors.New("invalid download link")
+	ErrLinkExpired = errors.New("download link expired")
+)
+
+// Links signs tokens for download links served by this service. A token
+// names the object and its expiry and is only valid with the secret that
+// signed it.
+type Links struct {
+	secret []byte
+}
+
+// NewLinks generates a random secret when none is configured; links then
+// stop working after a restart and are not accepted by other replicas.
+func NewLinks(secret string) (*Links, error) {
+	if secret != "" {
+		return &Links{secret: []byte(secret)}, nil
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate link secret: %w", err)
+	}
+	return &Links{secret: random}, nil
+}
+
+type linkClaims struct {
+	Key      string `json:"k"`
+	Filename string `json:"f"`
+	Expires  int64  `json:"e"`
+}
+
+func (l *Links) Sign(key, filename string, expires time.Time) string {
+	payload, _ := json.Marshal(linkClaims{Key: key, Filename: filename, Expires: expires.Unix()})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(l.mac(encoded))
+}
+
+// Verify returns the object key and download filename of a token.
+func (l *Links) Verify(token string, now time.Time) (key, filename string, err error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", ErrInvalidLink
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, l.mac(encoded)) {
+		return "", "", ErrInvalidLink
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", ErrInvalidLink
+	}
+	var claims linkClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || !ValidKey(claims.Key) {
+		return "", "", ErrInvalidLink
+	}
+	if now.Unix() >= claims.Expires {
+		return "", "", ErrLinkExpired
+	}
+	return claims.Key, claims.Filename, nil
+}
+
+func (l *Links) mac(payload string) []byte {
+	h := hmac.New(sha256.New, l.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
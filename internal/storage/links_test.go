@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinks(t *testing.T) {
+	links, err := NewLinks("secret")
+	if err != nil {
+		t.Fatalf("NewLinks: %v", err)
+	}
+
+	now := time.Now()
+	token := links.Sign("exports/default/a.zip", "prices.zip", now.Add(time.Minute))
+
+	key, filename, err := links.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if key != "exports/default/a.zip" || filename != "prices.zip" {
+		t.Errorf("Verify returned %q, %q", key, filename)
+	}
+
+	// Replicas sharing the secret accept each other's links.
+	other, _ := NewLinks("secret")
+	if _, _, err := other.Verify(token, now); err != nil {
+		t.Errorf("Verify with the same secret: %v", err)
+	}
+}
+
+func TestLinksExpiry(t *testing.T) {
+	links, _ := NewLinks("secret")
+	expires := time.Now().Add(time.Minute)
+	token := links.Sign("exports/default/a.zip", "prices.zip", expires)
+
+	if _, _, err := links.Verify(token, expires.Add(-time.Second)); err != nil {
+		t.Errorf("Verify before expiry: %v", err)
+	}
+	if _, _, err := links.Verify(token, expires); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("Verify at expiry: %v, want ErrLinkExpired", err)
+	}
+	if _, _, err := links.Verify(token, expires.Add(time.Hour)); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("Verify after expiry: %v, want ErrLinkExpired", err)
+	}
+}
+
+func TestLinksRejectsForgedTokens(t *testing.T) {
+	links, _ := NewLinks("secret")
+	now := time.Now()
+	token := links.Sign("exports/default/a.zip", "prices.zip", now.Add(time.Minute))
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// A link signed for another object must not open this one, and an
+	// expired link must not be extended by editing it.
+	otherPayload, _, _ := strings.Cut(links.Sign("exports/other/b.zip", "b.zip", now.Add(time.Hour)), ".")
+
+	random, err := NewLinks("")
+	if err != nil {
+		t.Fatalf("NewLinks without a secret: %v", err)
+	}
+
+	for name, token := range map[string]string{
+		"empty":            "",
+		"no signature":     payload,
+		"bad encoding":     payload + ".!!!",
+		"swapped payload":  otherPayload + "." + signature,
+		"truncated mac":    payload + "." + signature[:10],
+		"other secret":     mustSign(t, "other", now),
+		"random secret":    random.Sign("exports/default/a.zip", "prices.zip", now.Add(time.Minute)),
+		"invalid key":      links.Sign("../etc/passwd", "passwd", now.Add(time.Minute)),
+		"payload not json": "bm90IGpzb24." + base64.RawURLEncoding.EncodeToString(links.mac("bm90IGpzb24")),
+	} {
+		if _, _, err := links.Verify(token, now); !errors.Is(err, ErrInvalidLink) {
+			t.Errorf("%s: Verify returned %v, want ErrInvalidLink", name, err)
+		}
+	}
+}
+
+func mustSign(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+	links, err := NewLinks(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return links.Sign("exports/default/a.zip", "prices.zip", now.Add(time.Minute))
+}
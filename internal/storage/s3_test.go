@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"project_sem/internal/config"
+)
+
+// fakeS3 serves the part of the S3 API the backend uses, with path-style
+// addressing and without checking signatures.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func newFakeS3(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(&fakeS3{buckets: make(map[string]map[string]fakeObject)})
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, exists := f.buckets[bucket]
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = make(map[string]fakeObject)
+		case http.MethodGet:
+			if !exists {
+				writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+				return
+			}
+			f.list(w, bucket, objects, r.URL.Query())
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if !exists {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	object, found := objects[key]
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC()}
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodHead, http.MethodGet:
+		if !found {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Last-Modified", object.modTime.Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, bucket string, objects map[string]fakeObject, query url.Values) {
+	type content struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int    `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+	type result struct {
+		XMLName     xml.Name  `xml:"ListBucketResult"`
+		Name        string    `xml:"Name"`
+		Prefix      string    `xml:"Prefix"`
+		KeyCount    int       `xml:"KeyCount"`
+		MaxKeys     int       `xml:"MaxKeys"`
+		IsTruncated bool      `xml:"IsTruncated"`
+		Contents    []content `xml:"Contents"`
+	}
+
+	prefix := query.Get("prefix")
+	res := result{Name: bucket, Prefix: prefix, MaxKeys: 1000}
+	for key, object := range objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		res.Contents = append(res.Contents, content{
+			Key:          key,
+			LastModified: object.modTime.Format(time.RFC3339),
+			ETag:         `"etag"`,
+			Size:         len(object.data),
+			StorageClass: "STANDARD",
+		})
+	}
+	sort.Slice(res.Contents, func(i, j int) bool { return res.Contents[i].Key < res.Contents[j].Key })
+	res.KeyCount = len(res.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(res)
+}
+
+// readS3Body decodes aws-chunked bodies, which the client sends when it
+// signs the payload in chunks.
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// newTestS3 uses the service in TEST_S3_ENDPOINT, such as the MinIO of
+// docker-compose, with a new bucket, and fakeS3 without one.
+func newTestS3(t *testing.T) *S3 {
+	t.Helper()
+
+	cfg := config.StorageConfig{
+		S3Endpoint:  os.Getenv("TEST_S3_ENDPOINT"),
+		S3Region:    "us-east-1",
+		S3Bucket:    fmt.Sprintf("test-%d", time.Now().UnixNano()),
+		S3AccessKey: cmp.Or(os.Getenv("TEST_S3_ACCESS_KEY"), "minioadmin"),
+		S3SecretKey: cmp.Or(os.Getenv("TEST_S3_SECRET_KEY"), "minioadmin"),
+	}
+	if cfg.S3Endpoint == "" {
+		cfg.S3Endpoint = strings.TrimPrefix(newFakeS3(t).URL, "http://")
+	}
+
+	s, err := NewS3(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s
+}
+
+func TestS3(t *testing.T) {
+	ctx := context.Background()
+	s := newTestS3(t)
+
+	const key = "uploads/default/1/data.zip"
+	if err := s.Put(ctx, key, strings.NewReader("archive"), 7, "application/zip"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "archive" {
+		t.Fatalf("Get returned %q, %v", data, err)
+	}
+
+	object, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if object.Key != key || object.Size != 7 || object.ModTime.IsZero() {
+		t.Errorf("Stat returned %+v", object)
+	}
+
+	if err := s.Put(ctx, "exports/default/a.zip", strings.NewReader("x"), 1, "application/zip"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	objects, err := s.List(ctx, "uploads/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != key || objects[0].Size != 7 {
+		t.Errorf("List(uploads/) returned %+v", objects)
+	}
+
+	link, err := s.PresignGet(ctx, key, "prices.zip", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("PresignGet returned an invalid URL: %v", err)
+	}
+	if u.Path != "/"+s.bucket+"/"+key || u.Query().Get("X-Amz-Expires") != "60" ||
+		!strings.Contains(u.Query().Get("response-content-disposition"), "prices.zip") {
+		t.Errorf("PresignGet returned %s", link)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+	}
+	if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete: %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3InvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	s := newTestS3(t)
+
+	for _, key := range []string{"", "../escape", "/abs", "a/../b"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := s.Stat(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat(%q) = %v, want an invalid key error", key, err)
+		}
+		if _, err := s.PresignGet(ctx, key, "x", time.Minute); err == nil {
+			t.Errorf("PresignGet(%q) succeeded", key)
+		}
+	}
+}
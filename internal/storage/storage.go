@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"project_sem/internal/config"
+	"project_sem/internal/tenant"
+)
+
+var (
+	ErrNotFound = errors.New("object not found")
+	// ErrPresignUnsupported is returned by backends that cannot hand out
+	// URLs of their own; callers fall back to a signed link to this service.
+	ErrPresignUnsupported = errors.New("presigned URLs are not supported")
+)
+
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage keeps blobs under slash-separated keys.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]Object, error)
+	PresignGet(ctx context.Context, key, filename string, ttl time.Duration) (string, error)
+}
+
+// New returns nil when no backend is configured.
+func New(ctx context.Context, cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Backend {
+	case "none":
+		return nil, nil
+	case "filesystem":
+		return NewFilesystem(cfg.Dir)
+	case "s3":
+		return NewS3(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+// ValidKey rejects keys that are empty, absolute or that climb out of the
+// storage root.
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return false
+	}
+	return path.Clean(key) == key && key != "." && !strings.HasPrefix(key, "../") && key != ".."
+}
+
+func checkKey(key string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
+
+// PutUpload stores the original archive of a batch as
+// uploads/<tenant>/<batch>/<filename> and returns the key.
+func PutUpload(ctx context.Context, s Storage, batchID int64, filename, archiveType string, data []byte) (string, error) {
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if !ValidKey(filename) {
+		filename = "upload." + archiveType
+	}
+	key := fmt.Sprintf("uploads/%s/%d/%s", tenant.FromContext(ctx), batchID, filename)
+
+	contentType := "application/zip"
+	switch archiveType {
+	case "tar":
+		contentType = "application/x-tar"
+	case "csv":
+		contentType = "text/csv"
+	}
+
+	return key, s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+// RunPurge deletes objects under prefix older than maxAge every interval
+// until ctx is done.
+func RunPurge(ctx context.Context, s Storage, prefix string, maxAge, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purged, err := purge(ctx, s, prefix, now.Add(-maxAge))
+			if err != nil {
+				slog.Error("Failed to purge stored objects", "prefix", prefix, "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("Purged stored objects", "prefix", prefix, "count", purged)
+			}
+		}
+	}
+}
+
+func purge(ctx context.Context, s Storage, prefix string, before time.Time) (int, error) {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int
+	for _, object := range objects {
+		if object.ModTime.After(before) {
+			continue
+		}
+		if err := s.Delete(ctx, object.Key); err != nil && !errors.Is(err, ErrNotFound) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
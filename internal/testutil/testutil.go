@@ -0,0 +1,61 @@
+// Package testutil holds the fixtures shared by the tests that need a
+// database.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"project_sem/internal/database"
+	"project_sem/internal/tenant"
+)
+
+// DSN returns TEST_DATABASE_URL and skips the test without it. In CI, where
+// the database is always provided, a missing URL fails the test instead, so
+// that a broken workflow cannot pass by skipping every database test.
+func DSN(t testing.TB) string {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatal("TEST_DATABASE_URL is not set")
+		}
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	return dsn
+}
+
+// DB returns the migrated database in TEST_DATABASE_URL and skips the test
+// without one.
+func DB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("postgres", DSN(t))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return db
+}
+
+// TenantID returns a new tenant, so that tests sharing a database do not
+// see each other's rows.
+func TenantID() string {
+	return fmt.Sprintf("test-%d", time.Now().UnixNano())
+}
+
+// Tenant returns a context acting on a new tenant.
+func Tenant(t testing.TB) context.Context {
+	t.Helper()
+	return tenant.WithTenant(context.Background(), TenantID())
+}
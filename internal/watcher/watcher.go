@@ -0,0 +1,248 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"project_sem/internal/limits"
+	"project_sem/internal/logging"
+	"project_sem/internal/metrics"
+	"project_sem/internal/models"
+	"project_sem/internal/services"
+	"project_sem/internal/storage"
+	"project_sem/internal/tenant"
+)
+
+const (
+	doneDir   = "done"
+	failedDir = "failed"
+)
+
+type Options struct {
+	Dir      string
+	Interval time.Duration
+	// Notify also scans on filesystem events instead of only on the timer.
+	Notify bool
+	// Settle is how long a file must stay unmodified before it is picked
+	// up, so that files still being written by SFTP are left alone.
+	Settle     time.Duration
+	Tenant     string
+	Mode       models.UploadMode
+	IDStrategy models.IDStrategy
+}
+
+// Result is written next to every processed file as <file>.json.
+type Result struct {
+	File       string                 `json:"file"`
+	Status     string                 `json:"status"`
+	Tenant     string                 `json:"tenant"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+	Response   *models.UploadResponse `json:"response,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Watcher imports .zip, .tar and .csv files dropped into a directory and
+// moves them to done/ or failed/ with a Result sidecar.
+type Watcher struct {
+	opts    Options
+	imports *services.ImportService
+	slots   *limits.Semaphore
+	store   storage.Storage
+}
+
+// New creates the directory layout. slots is shared with the HTTP imports
+// and store, when not nil, keeps the originals like API uploads.
+func New(opts Options, imports *services.ImportService, slots *limits.Semaphore, store storage.Storage) (*Watcher, error) {
+	for _, dir := range []string{opts.Dir, filepath.Join(opts.Dir, doneDir), filepath.Join(opts.Dir, failedDir)} {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create watch directory: %w", err)
+		}
+	}
+	return &Watcher{opts: opts, imports: imports, slots: slots, store: store}, nil
+}
+
+// Run scans every interval, and on filesystem events when enabled, until
+// ctx is done. An import in progress when ctx is cancelled is rolled back
+// and its file stays in place for the next run.
+func (w *Watcher) Run(ctx context.Context) {
+	var events chan fsnotify.Event
+	if w.opts.Notify {
+		notifier, err := fsnotify.NewWatcher()
+		if err == nil {
+			err = notifier.Add(w.opts.Dir)
+		}
+		if err != nil {
+			slog.Warn("Filesystem notifications unavailable, scanning on schedule only", "dir", w.opts.Dir, "error", err)
+		} else {
+			defer notifier.Close()
+			events = notifier.Events
+		}
+	}
+
+	slog.Info("Watching import directory", "dir", w.opts.Dir, "interval", w.opts.Interval.String(), "notify", events != nil)
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	// A notification arrives when a file is created, before it is fully
+	// written, so the scan it triggers is delayed by the settle time.
+	settled := time.NewTimer(0)
+	defer settled.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Scan(ctx)
+		case <-settled.C:
+			w.Scan(ctx)
+		case event := <-events:
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) || event.Has(fsnotify.Rename) {
+				settled.Reset(w.opts.Settle + time.Second)
+			}
+		}
+	}
+}
+
+// Scan imports every settled file, oldest first.
+func (w *Watcher) Scan(ctx context.Context) {
+	entries, err := os.ReadDir(w.opts.Dir)
+	if err != nil {
+		slog.Error("Failed to read watch directory", "dir", w.opts.Dir, "error", err)
+		return
+	}
+
+	type candidate struct {
+		name    string
+		modTime time.Time
+	}
+	var files []candidate
+	now := time.Now()
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") || archiveType(entry.Name()) == "" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < w.opts.Settle {
+			continue
+		}
+		files = append(files, candidate{name: entry.Name(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		w.process(ctx, file.name)
+	}
+}
+
+func (w *Watcher) process(ctx context.Context, name string) {
+	logger := slog.Default().With("file", name, "tenant", w.opts.Tenant)
+	ctx = logging.WithLogger(tenant.WithTenant(ctx, w.opts.Tenant), logger)
+
+	release, err := w.slots.Acquire(ctx)
+	if err != nil {
+		logger.Info("No free import slot, retrying on next scan", "error", err)
+		metrics.WatchedFiles.WithLabelValues("retry").Inc()
+		return
+	}
+	defer release()
+
+	result := Result{File: name, Tenant: w.opts.Tenant, StartedAt: time.Now().UTC()}
+	path := filepath.Join(w.opts.Dir, name)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Error("Failed to read watched file", "error", err)
+		metrics.WatchedFiles.WithLabelValues("retry").Inc()
+		return
+	}
+
+	kind := archiveType(name)
+	opts := models.ImportOptions{
+		Mode:       w.opts.Mode,
+		IDStrategy: w.opts.IDStrategy,
+		Subject:    "watcher",
+		Source:     "watch:" + name,
+	}
+
+	response, err := w.imports.Import(ctx, data, kind, opts)
+	result.FinishedAt = time.Now().UTC()
+	switch {
+	case err != nil && !isRejectedContent(err):
+		// The database or the context failed, not the file: keep it for
+		// the next scan.
+		logger.Error("Watched file import failed, retrying on next scan", "error", err)
+		metrics.WatchedFiles.WithLabelValues("retry").Inc()
+		return
+	case err != nil:
+		logger.Warn("Watched file rejected", "error", err)
+		result.Status = failedDir
+		result.Error = err.Error()
+	default:
+		result.Status = doneDir
+		result.Response = response
+		if w.store != nil {
+			if key, err := storage.PutUpload(ctx, w.store, response.BatchID, name, kind, data); err != nil {
+				logger.Error("Failed to store watched file", "key", key, "error", err)
+			}
+		}
+	}
+
+	if err := w.finish(path, result); err != nil {
+		logger.Error("Failed to move watched file", "error", err)
+	}
+	metrics.WatchedFiles.WithLabelValues(result.Status).Inc()
+}
+
+// finish moves the file under a timestamped name, so that the same file
+// name can be dropped again, and writes the sidecar next to it.
+func (w *Watcher) finish(path string, result Result) error {
+	target := filepath.Join(w.opts.Dir, result.Status, result.StartedAt.Format("20060102T150405")+"-"+result.File)
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("failed to move %s: %w", result.File, err)
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result of %s: %w", result.File, err)
+	}
+	if err := os.WriteFile(target+".json", content, 0o640); err != nil {
+		return fmt.Errorf("failed to write result of %s: %w", result.File, err)
+	}
+	return nil
+}
+
+func archiveType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip":
+		return "zip"
+	case ".tar":
+		return "tar"
+	case ".csv":
+		return "csv"
+	default:
+		return ""
+	}
+}
+
+func isRejectedContent(err error) bool {
+	return errors.Is(err, services.ErrCorruptedArchive) ||
+		errors.Is(err, services.ErrInvalidCSV) ||
+		services.IsLimitError(err)
+}
@@ -11,7 +11,11 @@ if ! docker info > /dev/null 2>&1; then
 fi
 
 echo "Building ${FULL_IMAGE}..."
-docker build --platform linux/amd64 -t ${FULL_IMAGE} .
+docker build --platform linux/amd64 \
+    --build-arg VERSION="${IMAGE_TAG}" \
+    --build-arg COMMIT="$(git rev-parse --short HEAD 2>/dev/null || echo unknown)" \
+    --build-arg BUILD_DATE="$(date -u +%Y-%m-%dT%H:%M:%SZ)" \
+    -t ${FULL_IMAGE} .
 
 if ! docker images | grep -q "${DOCKER_IMAGE}"; then
     echo "Build failed"
@@ -166,7 +166,7 @@ echo "Ожидание готовности API..."
 max_attempts=60
 attempt=0
 
-while ! curl -s -o /dev/null http://${VM_IP}:8080/api/v0/prices 2>/dev/null; do
+while ! curl -sf -o /dev/null http://${VM_IP}:8080/readyz 2>/dev/null; do
     attempt=$((attempt + 1))
     if [ $attempt -ge $max_attempts ]; then
         echo "✗ API не стал доступен"
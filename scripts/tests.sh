@@ -236,6 +236,45 @@ check_api_complex() {
     return 0
 }
 
+check_api_concurrency() {
+    echo -e "\nПроверка API (параллельные загрузки)"
+
+    local rows=50
+    local uploads=5
+    local base_id=$(( ($(date +%s) % 100000) * 1000 + 100000000 ))
+    local responses_dir=$(mktemp -d)
+
+    echo "id,name,category,price,create_date" > $TEST_CSV
+    for i in $(seq 1 $rows); do
+        echo "$((base_id + i)),concurrent_${base_id}_${i},concurrent,$i,2024-02-01" >> $TEST_CSV
+    done
+    zip -q $TEST_ZIP $TEST_CSV
+
+    echo "Отправка ${uploads} параллельных запросов POST /api/v0/prices"
+    for n in $(seq 1 $uploads); do
+        curl -s -F "file=@$TEST_ZIP" "${API_HOST}/api/v0/prices" > "${responses_dir}/${n}.json" &
+    done
+    wait
+
+    local inserted=0
+    local duplicates=0
+    for f in "${responses_dir}"/*.json; do
+        local value=$(grep -o '"inserted_count":[0-9]*' "$f" | cut -d':' -f2)
+        local dup=$(grep -o '"duplicates_count":[0-9]*' "$f" | cut -d':' -f2)
+        inserted=$((inserted + ${value:-0}))
+        duplicates=$((duplicates + ${dup:-0}))
+    done
+    rm -rf "$responses_dir"
+
+    if [ $inserted -eq $rows ] && [ $duplicates -eq $((rows * (uploads - 1))) ]; then
+        echo -e "${GREEN}✓ Каждая запись вставлена ровно один раз (${inserted} из ${rows})${NC}"
+        return 0
+    fi
+
+    echo -e "${RED}✗ Вставлено ${inserted} записей и ${duplicates} дубликатов, ожидалось ${rows} и $((rows * (uploads - 1)))${NC}"
+    return 1
+}
+
 check_postgres() {
     local level=$1
 
@@ -366,6 +405,11 @@ main() {
             check_postgres 3
             failed=$((failed + $?))
             ;;
+        4)
+            echo "=== Запуск проверки параллельных загрузок ==="
+            check_api_concurrency
+            failed=$((failed + $?))
+            ;;
         *)
             echo "Неверный уровень проверки"
             cleanup
@@ -386,12 +430,13 @@ main() {
 }
 
 # Проверка аргументов
-if [ $# -ne 1 ] || ! [[ $1 =~ ^[1-3]$ ]]; then
+if [ $# -ne 1 ] || ! [[ $1 =~ ^[1-4]$ ]]; then
     echo "Использование: $0 <уровень_проверки>"
     echo "Уровень проверки должен быть:"
     echo "  1 - простой уровень"
     echo "  2 - продвинутый уровень"
     echo "  3 - сложный уровень"
+    echo "  4 - параллельные загрузки"
     exit 1
 fi
 